@@ -0,0 +1,76 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+)
+
+const (
+	APIVersionAuto = "auto"
+	APIVersionV2   = "v2"
+	APIVersionV3   = "v3"
+)
+
+type rootResponse struct {
+	Links struct {
+		CloudControllerV3 *Link `json:"cloud_controller_v3"`
+	} `json:"links"`
+}
+
+func (c *Client) NewGetRootRequest() (*http.Request, error) {
+	return c.newGetRequest("/", url.Values{}), nil
+}
+
+// UseAPIVersion selects the Cloud Controller API used by the client. In
+// auto mode the API root is probed and v3 is used whenever it is advertised.
+func (c *Client) UseAPIVersion(version string, httpClient CloudControllerClient) error {
+	switch version {
+	case APIVersionV2:
+		c.V3 = false
+	case APIVersionV3:
+		c.V3 = true
+	case APIVersionAuto, "":
+		v3, err := c.SupportsV3(httpClient)
+		if err != nil {
+			return err
+		}
+		c.V3 = v3
+	default:
+		return fmt.Errorf("Unknown Cloud Controller API version: %s", version)
+	}
+
+	return nil
+}
+
+func (c *Client) SupportsV3(httpClient CloudControllerClient) (bool, error) {
+	req, err := c.NewGetRootRequest()
+	if err != nil {
+		return false, err
+	}
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return false, err
+	}
+
+	var root rootResponse
+	err = json.Unmarshal(body, &root)
+	if err != nil {
+		return false, err
+	}
+
+	return root.Links.CloudControllerV3 != nil, nil
+}
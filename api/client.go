@@ -6,13 +6,20 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
 
 	"github.com/cloudfoundry/cli/plugin/models"
 )
 
+const (
+	v2MaxResultsPerPage = 100
+	v3MaxPerPage        = 5000
+)
+
 type Client struct {
-	BaseUrl   *url.URL
-	AuthToken string
+	BaseUrl *url.URL
+	Tokens  *TokenSource
+	V3      bool
 }
 
 //go:generate counterfeiter . Connection
@@ -50,37 +57,108 @@ func NewClient(connection Connection) (*Client, error) {
 		return nil, err
 	}
 
-	authToken, err := connection.AccessToken()
+	tokens := NewTokenSource(connection)
+	_, err = tokens.Token()
 	if err != nil {
 		return nil, err
 	}
 
 	client := &Client{
-		BaseUrl:   u,
-		AuthToken: authToken,
+		BaseUrl: u,
+		Tokens:  tokens,
 	}
 
 	return client, nil
 }
 
 func (c *Client) NewGetAppsRequest() (*http.Request, error) {
-	req := &http.Request{
-		Method: "GET",
-		URL:    c.BaseUrl,
+	if c.V3 {
+		return c.newListRequest("/v3/apps", url.Values{}), nil
+	}
+
+	return c.newListRequest("/v2/apps", url.Values{}), nil
+}
+
+// NewGetAppRequest makes an authorized request for a single app by guid.
+func (c *Client) NewGetAppRequest(guid string) (*http.Request, error) {
+	path := "/v2/apps/" + url.PathEscape(guid)
+	if c.V3 {
+		path = "/v3/apps/" + url.PathEscape(guid)
 	}
-	req.URL.Path = "/v2/apps"
 
-	return req, nil
+	return c.Authorize(func() (*http.Request, error) {
+		return c.newGetRequest(path, url.Values{}), nil
+	})()
 }
 
 func (c *Client) NewGetSpacesRequest() (*http.Request, error) {
-	req := &http.Request{
-		Method: "GET",
-		URL:    c.BaseUrl,
+	if c.V3 {
+		return c.newListRequest("/v3/spaces", url.Values{"include": {"organization"}}), nil
+	}
+
+	return c.newListRequest("/v2/spaces", url.Values{"inline-relations-depth": {"1"}}), nil
+}
+
+// NewGetProcessesRequest lists the web processes, which hold the memory of
+// the apps on v3.
+func (c *Client) NewGetProcessesRequest() (*http.Request, error) {
+	return c.newListRequest("/v3/processes", url.Values{"types": {"web"}}), nil
+}
+
+func (c *Client) NewGetStacksRequest() (*http.Request, error) {
+	if c.V3 {
+		return c.newListRequest("/v3/stacks", url.Values{}), nil
+	}
+
+	return c.newListRequest("/v2/stacks", url.Values{}), nil
+}
+
+// NewGetCurrentDropletRequest makes an authorized request for the droplet an
+// app runs, which only v3 has.
+func (c *Client) NewGetCurrentDropletRequest(appGuid string) (*http.Request, error) {
+	return c.Authorize(func() (*http.Request, error) {
+		return c.newGetRequest("/v3/apps/"+url.PathEscape(appGuid)+"/droplets/current", url.Values{}), nil
+	})()
+}
+
+// newListRequest asks for the largest pages the API allows, to keep the
+// number of requests for large foundations down.
+func (c *Client) newListRequest(path string, query url.Values) *http.Request {
+	if c.V3 {
+		query.Set("per_page", strconv.Itoa(v3MaxPerPage))
+	} else {
+		query.Set("results-per-page", strconv.Itoa(v2MaxResultsPerPage))
+	}
+
+	return c.newGetRequest(path, query)
+}
+
+// NewLinkRequest makes an authorized request for a link returned by the Cloud
+// Controller, such as the next_url of a page, which v2 returns relative to
+// the API endpoint.
+func (c *Client) NewLinkRequest(link string) (*http.Request, error) {
+	u, err := c.BaseUrl.Parse(link)
+	if err != nil {
+		return new(http.Request), err
 	}
-	req.URL.Path = "/v2/spaces"
 
-	return req, nil
+	return c.Authorize(func() (*http.Request, error) {
+		return &http.Request{
+			Method: "GET",
+			URL:    u,
+		}, nil
+	})()
+}
+
+func (c *Client) newGetRequest(path string, query url.Values) *http.Request {
+	u := *c.BaseUrl
+	u.Path = path
+	u.RawQuery = query.Encode()
+
+	return &http.Request{
+		Method: "GET",
+		URL:    &u,
+	}
 }
 
 func (c *Client) HandleFiltersAndParameters(next func() (*http.Request, error)) func(filter Filter, params map[string]interface{}) (*http.Request, error) {
@@ -90,7 +168,20 @@ func (c *Client) HandleFiltersAndParameters(next func() (*http.Request, error))
 			return new(http.Request), err
 		}
 
-		req.URL.RawQuery = generateParams(filter, params).Encode()
+		values := req.URL.Query()
+
+		var generated url.Values
+		if c.V3 {
+			generated = generateV3Params(filter, params)
+		} else {
+			generated = generateParams(filter, params)
+		}
+
+		for k, v := range generated {
+			values[k] = v
+		}
+
+		req.URL.RawQuery = values.Encode()
 		return req, nil
 	}
 }
@@ -102,8 +193,13 @@ func (c *Client) Authorize(next func() (*http.Request, error)) func() (*http.Req
 			return new(http.Request), err
 		}
 
+		authToken, err := c.Tokens.Token()
+		if err != nil {
+			return new(http.Request), err
+		}
+
 		header := http.Header{}
-		header.Set("Authorization", c.AuthToken)
+		header.Set("Authorization", authToken)
 
 		req.Header = header
 		return req, nil
@@ -123,6 +219,15 @@ func generateParams(filter Filter, params map[string]interface{}) url.Values {
 	return values
 }
 
+func generateV3Params(filter Filter, params map[string]interface{}) url.Values {
+	values := V3QueryParams(filter)
+
+	for k, v := range params {
+		values.Set(k, fmt.Sprint(v))
+	}
+	return values
+}
+
 func NewHttpClient(cliConnection Connection) (*http.Client, error) {
 	skipVerify, err := cliConnection.IsSSLDisabled()
 	if err != nil {
@@ -1,7 +1,9 @@
 package api_test
 
 import (
+	"io/ioutil"
 	"net/http"
+	"strings"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -113,7 +115,32 @@ var _ = Describe("Api", func() {
 
 		It("hits the appropriate API URL", func() {
 			Expect(request.Method).To(Equal("GET"))
-			Expect(request.URL.String()).To(Equal("https://api.my-crazy-domain.com/v2/apps"))
+			Expect(request.URL.String()).To(Equal("https://api.my-crazy-domain.com/v2/apps?results-per-page=100"))
+		})
+	})
+
+	Describe("NewGetAppRequest", func() {
+		It("hits the URL of the app", func() {
+			request, err = apiClient.NewGetAppRequest("some-app-guid")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(request.Method).To(Equal("GET"))
+			Expect(request.URL.String()).To(Equal("https://api.my-crazy-domain.com/v2/apps/some-app-guid"))
+			Expect(request.Header.Get("Authorization")).To(Equal(authToken))
+		})
+	})
+
+	Describe("NewLinkRequest", func() {
+		It("resolves v2 links against the API endpoint and authorizes the request", func() {
+			request, err = apiClient.NewLinkRequest("/v2/apps?page=2&results-per-page=100")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(request.URL.String()).To(Equal("https://api.my-crazy-domain.com/v2/apps?page=2&results-per-page=100"))
+			Expect(request.Header.Get("Authorization")).To(Equal(authToken))
+		})
+
+		It("keeps absolute v3 links", func() {
+			request, err = apiClient.NewLinkRequest("https://api.my-crazy-domain.com/v3/apps?page=2&per_page=5000")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(request.URL.String()).To(Equal("https://api.my-crazy-domain.com/v3/apps?page=2&per_page=5000"))
 		})
 	})
 
@@ -124,7 +151,125 @@ var _ = Describe("Api", func() {
 
 		It("hits the appropriate API URL", func() {
 			Expect(request.Method).To(Equal("GET"))
-			Expect(request.URL.String()).To(Equal("https://api.my-crazy-domain.com/v2/spaces"))
+			Expect(request.URL.String()).To(Equal("https://api.my-crazy-domain.com/v2/spaces?inline-relations-depth=1&results-per-page=100"))
+		})
+	})
+
+	Describe("NewGetStacksRequest", func() {
+		It("hits the stacks URL", func() {
+			request, err = apiClient.NewGetStacksRequest()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(request.URL.String()).To(Equal("https://api.my-crazy-domain.com/v2/stacks?results-per-page=100"))
+		})
+	})
+
+	Context("when using the v3 API", func() {
+		JustBeforeEach(func() {
+			apiClient.V3 = true
+		})
+
+		Describe("NewGetAppsRequest", func() {
+			It("hits the v3 apps URL", func() {
+				request, err = apiClient.NewGetAppsRequest()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(request.URL.String()).To(Equal("https://api.my-crazy-domain.com/v3/apps?per_page=5000"))
+			})
+		})
+
+		Describe("NewGetSpacesRequest", func() {
+			It("hits the v3 spaces URL including their organizations", func() {
+				request, err = apiClient.NewGetSpacesRequest()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(request.URL.String()).To(Equal("https://api.my-crazy-domain.com/v3/spaces?include=organization&per_page=5000"))
+			})
+		})
+
+		Describe("NewGetProcessesRequest", func() {
+			It("hits the v3 processes URL for web processes", func() {
+				request, err = apiClient.NewGetProcessesRequest()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(request.URL.String()).To(Equal("https://api.my-crazy-domain.com/v3/processes?per_page=5000&types=web"))
+			})
+		})
+
+		Describe("NewGetStacksRequest", func() {
+			It("filters the v3 stacks by name", func() {
+				requestFactory := apiClient.HandleFiltersAndParameters(apiClient.NewGetStacksRequest)
+				request, err = requestFactory(EqualFilter{Name: "name", Value: "cflinuxfs3"}, map[string]interface{}{})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(request.URL.String()).To(Equal("https://api.my-crazy-domain.com/v3/stacks?names=cflinuxfs3&per_page=5000"))
+			})
+		})
+
+		Describe("NewGetCurrentDropletRequest", func() {
+			It("hits the current droplet of the app", func() {
+				request, err = apiClient.NewGetCurrentDropletRequest("some-app-guid")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(request.URL.String()).To(Equal("https://api.my-crazy-domain.com/v3/apps/some-app-guid/droplets/current"))
+				Expect(request.Header.Get("Authorization")).To(Equal(authToken))
+			})
+		})
+
+		Describe("HandleFiltersAndParameters", func() {
+			It("translates filters into v3 query parameters and keeps the request's own", func() {
+				requestFactory := apiClient.HandleFiltersAndParameters(apiClient.NewGetSpacesRequest)
+				request, err = requestFactory(EqualFilter{Name: "organization_guid", Value: "org-guid"}, map[string]interface{}{"page": 2})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(request.URL.Query().Get("q")).To(BeEmpty())
+				Expect(request.URL.Query().Get("organization_guids")).To(Equal("org-guid"))
+				Expect(request.URL.Query().Get("include")).To(Equal("organization"))
+				Expect(request.URL.Query().Get("page")).To(Equal("2"))
+			})
+		})
+	})
+
+	Describe("UseAPIVersion", func() {
+		var fakeCloudControllerClient *apifakes.FakeCloudControllerClient
+
+		respondWith := func(status int, body string) {
+			fakeCloudControllerClient.DoReturns(&http.Response{
+				StatusCode: status,
+				Body:       ioutil.NopCloser(strings.NewReader(body)),
+			}, nil)
+		}
+
+		BeforeEach(func() {
+			fakeCloudControllerClient = new(apifakes.FakeCloudControllerClient)
+		})
+
+		It("uses the forced version without probing", func() {
+			Expect(apiClient.UseAPIVersion(APIVersionV3, fakeCloudControllerClient)).To(Succeed())
+			Expect(apiClient.V3).To(BeTrue())
+			Expect(fakeCloudControllerClient.DoCallCount()).To(Equal(0))
+		})
+
+		Context("when detecting the version", func() {
+			It("uses v3 when the API root advertises it", func() {
+				respondWith(http.StatusOK, `{"links":{"cloud_controller_v3":{"href":"https://api.my-crazy-domain.com/v3"}}}`)
+
+				Expect(apiClient.UseAPIVersion(APIVersionAuto, fakeCloudControllerClient)).To(Succeed())
+				Expect(apiClient.V3).To(BeTrue())
+				Expect(fakeCloudControllerClient.DoArgsForCall(0).URL.String()).To(Equal("https://api.my-crazy-domain.com/"))
+			})
+
+			It("uses v2 when the API root does not advertise v3", func() {
+				respondWith(http.StatusOK, `{"links":{"cloud_controller_v2":{"href":"https://api.my-crazy-domain.com/v2"}}}`)
+
+				Expect(apiClient.UseAPIVersion(APIVersionAuto, fakeCloudControllerClient)).To(Succeed())
+				Expect(apiClient.V3).To(BeFalse())
+			})
+
+			It("uses v2 when there is no API root", func() {
+				respondWith(http.StatusNotFound, "")
+
+				Expect(apiClient.UseAPIVersion(APIVersionAuto, fakeCloudControllerClient)).To(Succeed())
+				Expect(apiClient.V3).To(BeFalse())
+			})
+		})
+
+		It("rejects unknown versions", func() {
+			Expect(apiClient.UseAPIVersion("v4", fakeCloudControllerClient)).NotTo(Succeed())
 		})
 	})
 
@@ -164,6 +309,19 @@ var _ = Describe("Api", func() {
 		})
 	})
 
+	Describe("V3QueryParams", func() {
+		It("pluralizes names and joins values with commas", func() {
+			filter := Filters{
+				EqualFilter{Name: "organization_guid", Value: "org-guid"},
+				InclusionFilter{Name: "space_guid", Values: []interface{}{"space-1", "space-2"}},
+			}
+
+			params := V3QueryParams(filter)
+			Expect(params.Get("organization_guids")).To(Equal("org-guid"))
+			Expect(params.Get("space_guids")).To(Equal("space-1,space-2"))
+		})
+	})
+
 	Describe("Filters", func() {
 		It("combines its filters together with semicolons", func() {
 			filter1 := new(apifakes.FakeFilter)
@@ -286,6 +444,26 @@ var _ = Describe("Api", func() {
 			pages, err := PageParser{}.Parse([]byte(jsonBody))
 			Expect(err).NotTo(HaveOccurred())
 			Expect(pages.TotalPages).To(Equal(1))
+			Expect(pages.NextUrl).To(BeEmpty())
+		})
+
+		It("parses the total pages of v3 responses", func() {
+			jsonBody := `{
+   "pagination": {
+      "total_results": 3,
+      "total_pages": 2,
+      "first": { "href": "https://api.example.org/v3/apps?page=1&per_page=2" },
+      "last": { "href": "https://api.example.org/v3/apps?page=2&per_page=2" },
+      "next": { "href": "https://api.example.org/v3/apps?page=2&per_page=2" },
+      "previous": null
+   },
+   "resources": []
+}`
+			pages, err := PageParser{}.Parse([]byte(jsonBody))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pages.TotalPages).To(Equal(2))
+			Expect(pages.Pagination.Next.Href).To(Equal("https://api.example.org/v3/apps?page=2&per_page=2"))
+			Expect(pages.NextUrl).To(Equal("https://api.example.org/v3/apps?page=2&per_page=2"))
 		})
 	})
 })
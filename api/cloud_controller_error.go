@@ -0,0 +1,69 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// CloudControllerError is returned for responses with a status code outside
+// of 2xx. ErrorCode and Description are empty when the body is not a Cloud
+// Controller error, e.g. an HTML page served by the router.
+type CloudControllerError struct {
+	StatusCode  int
+	ErrorCode   string
+	Description string
+	RequestID   string
+}
+
+func (e CloudControllerError) Error() string {
+	message := fmt.Sprintf("Cloud Controller responded with %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+
+	if e.ErrorCode != "" {
+		message += ": " + e.ErrorCode
+		if e.Description != "" {
+			message += " - " + e.Description
+		}
+	}
+
+	if e.RequestID != "" {
+		message += " (request id: " + e.RequestID + ")"
+	}
+
+	return message
+}
+
+type cloudControllerErrorBody struct {
+	Description string `json:"description"`
+	ErrorCode   string `json:"error_code"`
+	Errors      []struct {
+		Title  string `json:"title"`
+		Detail string `json:"detail"`
+	} `json:"errors"`
+}
+
+func newCloudControllerError(res *http.Response, body []byte) CloudControllerError {
+	ccErr := CloudControllerError{
+		StatusCode: res.StatusCode,
+		RequestID:  res.Header.Get("X-Vcap-Request-Id"),
+	}
+
+	var errorBody cloudControllerErrorBody
+	if json.Unmarshal(body, &errorBody) != nil {
+		return ccErr
+	}
+
+	if len(errorBody.Errors) > 0 {
+		ccErr.ErrorCode = errorBody.Errors[0].Title
+		ccErr.Description = errorBody.Errors[0].Detail
+	} else {
+		ccErr.ErrorCode = errorBody.ErrorCode
+		ccErr.Description = errorBody.Description
+	}
+
+	return ccErr
+}
+
+func successful(res *http.Response) bool {
+	return res.StatusCode >= 200 && res.StatusCode < 300
+}
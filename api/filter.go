@@ -2,6 +2,7 @@ package api
 
 import (
 	"fmt"
+	"net/url"
 	"strings"
 )
 
@@ -45,3 +46,29 @@ func (f InclusionFilter) ToFilterQueryParam() string {
 
 	return fmt.Sprintf("%s IN %v", f.Name, strings.Join(vals, ","))
 }
+
+// V3QueryParams translates filters into the query parameters of the v3 API,
+// which filters on pluralized names with comma separated values, e.g.
+// space_guid:abc becomes space_guids=abc.
+func V3QueryParams(filter Filter) url.Values {
+	values := url.Values{}
+
+	switch f := filter.(type) {
+	case EqualFilter:
+		values.Set(f.Name+"s", fmt.Sprint(f.Value))
+	case InclusionFilter:
+		var vals []string
+		for _, v := range f.Values {
+			vals = append(vals, fmt.Sprint(v))
+		}
+		values.Set(f.Name+"s", strings.Join(vals, ","))
+	case Filters:
+		for _, x := range f {
+			for k, v := range V3QueryParams(x) {
+				values[k] = v
+			}
+		}
+	}
+
+	return values
+}
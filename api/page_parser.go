@@ -3,7 +3,18 @@ package api
 import "encoding/json"
 
 type PaginatedResponse struct {
-	TotalPages int `json:"total_pages"`
+	TotalPages int        `json:"total_pages"`
+	NextUrl    string     `json:"next_url"`
+	Pagination Pagination `json:"pagination"`
+}
+
+type Pagination struct {
+	TotalPages int   `json:"total_pages"`
+	Next       *Link `json:"next"`
+}
+
+type Link struct {
+	Href string `json:"href"`
 }
 
 type PageParser struct{}
@@ -17,5 +28,13 @@ func (p PageParser) Parse(body []byte) (PaginatedResponse, error) {
 		return emptyPages, err
 	}
 
+	if pages.TotalPages == 0 {
+		pages.TotalPages = pages.Pagination.TotalPages
+	}
+
+	if pages.NextUrl == "" && pages.Pagination.Next != nil {
+		pages.NextUrl = pages.Pagination.Next.Href
+	}
+
 	return pages, nil
 }
@@ -3,12 +3,18 @@ package api
 import (
 	"io/ioutil"
 	"net/http"
+	"strconv"
+	"sync"
 )
 
 //go:generate counterfeiter . RequestFactory
 //TODO: Fix counterfeiter to find Filter correctly #NoFilter
 type RequestFactory func(Filter, map[string]interface{}) (*http.Request, error)
 
+// LinkRequestFactory makes the request for a link returned by the Cloud
+// Controller, such as the next page.
+type LinkRequestFactory func(string) (*http.Request, error)
+
 //go:generate counterfeiter . CloudControllerClient
 type CloudControllerClient interface {
 	Do(*http.Request) (*http.Response, error)
@@ -19,76 +25,261 @@ type PaginatedParser interface {
 	Parse([]byte) (PaginatedResponse, error)
 }
 
+// PageIterator hands out the bodies of the pages of a listing one at a time.
+// Close must be called when the caller stops before the last page.
+type PageIterator interface {
+	Next() bool
+	Body() []byte
+	Err() error
+	Close()
+}
+
+const (
+	// defaultPageConcurrency is the number of pages Pages fetches at the
+	// same time once the number of pages is known.
+	defaultPageConcurrency = 4
+
+	// defaultPagePrefetch is the number of pages Pages fetches ahead of the
+	// caller.
+	defaultPagePrefetch = 2
+)
+
 type PaginatedRequester struct {
-	RequestFactory RequestFactory
-	Client         CloudControllerClient
-	PageParser     PaginatedParser
+	RequestFactory     RequestFactory
+	LinkRequestFactory LinkRequestFactory
+	Client             CloudControllerClient
+	PageParser         PaginatedParser
+	Concurrency        int
+	Prefetch           int
 }
 
-func NewPaginatedRequester(cliConnection Connection, requestFactory RequestFactory) (*PaginatedRequester, error) {
+func NewPaginatedRequester(
+	client CloudControllerClient,
+	requestFactory RequestFactory,
+	linkRequestFactory LinkRequestFactory,
+) *PaginatedRequester {
 	pageParser := PageParser{}
 
-	httpClient, err := NewHttpClient(cliConnection)
-	if err != nil {
-		return nil, err
+	return &PaginatedRequester{
+		RequestFactory:     requestFactory,
+		LinkRequestFactory: linkRequestFactory,
+		Client:             client,
+		PageParser:         pageParser,
+		Concurrency:        defaultPageConcurrency,
+		Prefetch:           defaultPagePrefetch,
 	}
+}
 
-	return &PaginatedRequester{
-		RequestFactory: requestFactory,
-		Client:         httpClient,
-		PageParser:     pageParser,
-	}, nil
+// Pages returns an iterator over the pages of a listing. Once the first page
+// tells the number of pages, the remaining pages are fetched concurrently,
+// Concurrency at a time; otherwise the next link of every page is followed.
+// Either way up to Prefetch pages are fetched ahead of the caller and the
+// pages are handed out in order.
+func (p *PaginatedRequester) Pages(filter Filter, params map[string]interface{}) PageIterator {
+	prefetch := p.Prefetch
+	if prefetch < 0 {
+		prefetch = 0
+	}
+
+	iterator := &pageIterator{
+		pages: make(chan pageResult, prefetch),
+		done:  make(chan struct{}),
+	}
+
+	go func() {
+		defer close(iterator.pages)
+
+		req, err := p.RequestFactory(filter, params)
+		for page := 1; ; page++ {
+			var body []byte
+			var paginatedRes PaginatedResponse
+			if err == nil {
+				body, err = p.fetchPage(req, nil)
+			}
+			if err == nil {
+				paginatedRes, err = p.PageParser.Parse(body)
+			}
+
+			if !iterator.send(pageResult{body: body, err: err}) {
+				return
+			}
+
+			switch {
+			case err != nil:
+				return
+			case page == 1 && paginatedRes.TotalPages > 1:
+				p.fetchRemainingPages(iterator, filter, params, paginatedRes)
+				return
+			case paginatedRes.NextUrl != "" && p.LinkRequestFactory != nil:
+				req, err = p.LinkRequestFactory(paginatedRes.NextUrl)
+			default:
+				return
+			}
+		}
+	}()
+
+	return iterator
 }
 
-func (p *PaginatedRequester) Do(filter Filter, params map[string]interface{}) ([][]byte, error) {
-	var noBodies [][]byte
+// fetchRemainingPages fetches pages 2 up to the total number of pages with
+// no more than Concurrency requests in flight, and stops at the first page
+// that fails.
+func (p *PaginatedRequester) fetchRemainingPages(
+	iterator *pageIterator,
+	filter Filter,
+	params map[string]interface{},
+	firstPage PaginatedResponse,
+) {
+	workers := p.Concurrency
+	if workers < 1 {
+		workers = 1
+	}
 
-	req, err := p.RequestFactory(filter, params)
-	if err != nil {
-		return noBodies, err
+	// Each page gets its own result channel, queued in page order. The
+	// queue holds the pages in flight besides the one being handed out.
+	queue := make(chan chan pageResult, workers-1)
+	stop := make(chan struct{})
+	defer close(stop)
+
+	go func() {
+		defer close(queue)
+
+		for page := 2; page <= firstPage.TotalPages; page++ {
+			result := make(chan pageResult, 1)
+
+			select {
+			case queue <- result:
+			case <-stop:
+				return
+			}
+
+			go func(page int) {
+				body, err := p.fetchPage(p.pageRequest(filter, params, firstPage.NextUrl, page))
+				result <- pageResult{body: body, err: err}
+			}(page)
+		}
+	}()
+
+	for result := range queue {
+		var res pageResult
+		select {
+		case res = <-result:
+		case <-iterator.done:
+			return
+		}
+
+		if !iterator.send(res) || res.err != nil {
+			return
+		}
+	}
+}
+
+// pageRequest makes the request for the given page, from the next link of
+// the first page when there is one and from the caller's params otherwise.
+// The params are copied, so that the caller's map is left untouched.
+func (p *PaginatedRequester) pageRequest(filter Filter, params map[string]interface{}, nextUrl string, page int) (*http.Request, error) {
+	if nextUrl != "" && p.LinkRequestFactory != nil {
+		req, err := p.LinkRequestFactory(nextUrl)
+		if err != nil {
+			return req, err
+		}
+
+		query := req.URL.Query()
+		query.Set("page", strconv.Itoa(page))
+		req.URL.RawQuery = query.Encode()
+
+		return req, nil
 	}
 
-	var responseBodies [][]byte
+	pageParams := map[string]interface{}{"page": page}
+	for k, v := range params {
+		if k != "page" {
+			pageParams[k] = v
+		}
+	}
+
+	return p.RequestFactory(filter, pageParams)
+}
 
-	res, err := p.Client.Do(req)
+func (p *PaginatedRequester) fetchPage(req *http.Request, err error) ([]byte, error) {
 	if err != nil {
-		return noBodies, err
+		return nil, err
 	}
 
+	return Get(p.Client, req)
+}
+
+// Get sends the request and returns the body of the response, or a
+// CloudControllerError when the Cloud Controller rejected the request.
+func Get(client CloudControllerClient, req *http.Request) ([]byte, error) {
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
 	defer res.Body.Close()
+
 	body, err := ioutil.ReadAll(res.Body)
 	if err != nil {
-		return noBodies, err
+		return nil, err
 	}
 
-	responseBodies = append(responseBodies, body)
-
-	paginatedRes, err := p.PageParser.Parse(body)
-	if err != nil {
-		return noBodies, err
+	if !successful(res) {
+		return nil, newCloudControllerError(res, body)
 	}
-	for page := 2; page <= paginatedRes.TotalPages; page++ {
-		// construct a new request with the current page
-		params["page"] = page
-		req, err := p.RequestFactory(filter, params)
-		if err != nil {
-			return noBodies, err
-		}
 
-		// perform the request
-		res, err := p.Client.Do(req)
-		if err != nil {
-			return noBodies, err
-		}
+	return body, nil
+}
 
-		defer res.Body.Close()
-		body, err = ioutil.ReadAll(res.Body)
-		if err != nil {
-			return noBodies, err
-		}
+type pageResult struct {
+	body []byte
+	err  error
+}
 
-		responseBodies = append(responseBodies, body)
+type pageIterator struct {
+	pages     chan pageResult
+	done      chan struct{}
+	closeOnce sync.Once
+
+	body []byte
+	err  error
+}
+
+// send hands a page to the caller and reports false once the caller closed
+// the iterator.
+func (i *pageIterator) send(result pageResult) bool {
+	select {
+	case i.pages <- result:
+		return true
+	case <-i.done:
+		return false
 	}
+}
+
+func (i *pageIterator) Next() bool {
+	if i.err != nil {
+		return false
+	}
+
+	result, ok := <-i.pages
+	if !ok {
+		i.body = nil
+		return false
+	}
+
+	i.body, i.err = result.body, result.err
+	return i.err == nil
+}
+
+func (i *pageIterator) Body() []byte {
+	return i.body
+}
+
+func (i *pageIterator) Err() error {
+	return i.err
+}
 
-	return responseBodies, nil
+func (i *pageIterator) Close() {
+	i.closeOnce.Do(func() {
+		close(i.done)
+	})
 }
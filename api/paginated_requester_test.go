@@ -2,6 +2,9 @@ package api_test
 
 import (
 	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
 
 	"io/ioutil"
 	"net/http"
@@ -57,7 +60,14 @@ var _ = Describe("PaginatedRequester", func() {
 	})
 
 	JustBeforeEach(func() {
-		responseBodies, err = paginatedRequester.Do(fakeFilter, params)
+		pages := paginatedRequester.Pages(fakeFilter, params)
+		defer pages.Close()
+
+		responseBodies = nil
+		for pages.Next() {
+			responseBodies = append(responseBodies, pages.Body())
+		}
+		err = pages.Err()
 	})
 
 	It("should create a request", func() {
@@ -106,6 +116,60 @@ var _ = Describe("PaginatedRequester", func() {
 			})
 		})
 
+		Context("when the Cloud Controller responds with an error", func() {
+			BeforeEach(func() {
+				fakeCloudControllerClient.DoReturns(&http.Response{
+					Status:     "401 Unauthorized",
+					StatusCode: http.StatusUnauthorized,
+					Header:     http.Header{"X-Vcap-Request-Id": []string{"some-request-id"}},
+					Body:       ioutil.NopCloser(strings.NewReader(`{"code":1000,"description":"Invalid Auth Token","error_code":"CF-InvalidAuthToken"}`)),
+				}, nil)
+			})
+
+			It("returns a CloudControllerError without parsing the body", func() {
+				Expect(responseBodies).To(BeEmpty())
+				Expect(err).To(Equal(api.CloudControllerError{
+					StatusCode:  http.StatusUnauthorized,
+					ErrorCode:   "CF-InvalidAuthToken",
+					Description: "Invalid Auth Token",
+					RequestID:   "some-request-id",
+				}))
+				Expect(err.Error()).To(Equal("Cloud Controller responded with 401 Unauthorized: CF-InvalidAuthToken - Invalid Auth Token (request id: some-request-id)"))
+				Expect(fakePaginatedParser.ParseCallCount()).To(Equal(0))
+			})
+
+			Context("when the error is in the v3 format", func() {
+				BeforeEach(func() {
+					fakeCloudControllerClient.DoReturns(&http.Response{
+						StatusCode: http.StatusNotFound,
+						Body:       ioutil.NopCloser(strings.NewReader(`{"errors":[{"code":10010,"title":"CF-ResourceNotFound","detail":"App not found"}]}`)),
+					}, nil)
+				})
+
+				It("takes the error code and description from the first error", func() {
+					Expect(err).To(Equal(api.CloudControllerError{
+						StatusCode:  http.StatusNotFound,
+						ErrorCode:   "CF-ResourceNotFound",
+						Description: "App not found",
+					}))
+				})
+			})
+
+			Context("when the body is not JSON", func() {
+				BeforeEach(func() {
+					fakeCloudControllerClient.DoReturns(&http.Response{
+						StatusCode: http.StatusBadGateway,
+						Body:       ioutil.NopCloser(strings.NewReader("<html>502 Bad Gateway</html>")),
+					}, nil)
+				})
+
+				It("returns the status only", func() {
+					Expect(err).To(Equal(api.CloudControllerError{StatusCode: http.StatusBadGateway}))
+					Expect(err.Error()).To(Equal("Cloud Controller responded with 502 Bad Gateway"))
+				})
+			})
+		})
+
 		Context("when making the request succeeds", func() {
 			response := generateApiResponse("")
 
@@ -182,7 +246,181 @@ var _ = Describe("PaginatedRequester", func() {
 						}))
 					})
 				})
+
+				Context("when there are more pages than concurrent requests", func() {
+					BeforeEach(func() {
+						paginatedRequester.Concurrency = 3
+						params["results-per-page"] = 100
+
+						fakePaginatedParser.ParseReturns(api.PaginatedResponse{
+							TotalPages: 6,
+						}, nil)
+
+						fakeRequestFactory.Stub = func(_ api.Filter, params map[string]interface{}) (*http.Request, error) {
+							return http.NewRequest("GET", fmt.Sprintf("/v2/apps?page=%v", params["page"]), nil)
+						}
+
+						fakeCloudControllerClient.DoStub = func(req *http.Request) (*http.Response, error) {
+							return generateApiResponse("body-of-page-" + req.URL.Query().Get("page")), nil
+						}
+					})
+
+					It("fetches every page once", func() {
+						Expect(fakeCloudControllerClient.DoCallCount()).To(Equal(6))
+					})
+
+					It("keeps the response bodies in page order", func() {
+						Expect(responseBodies).To(Equal([][]byte{
+							[]byte("body-of-page-<nil>"),
+							[]byte("body-of-page-2"),
+							[]byte("body-of-page-3"),
+							[]byte("body-of-page-4"),
+							[]byte("body-of-page-5"),
+							[]byte("body-of-page-6"),
+						}))
+					})
+
+					Context("when the pages take a while", func() {
+						var inFlight, maxInFlight int32
+
+						BeforeEach(func() {
+							inFlight, maxInFlight = 0, 0
+
+							fakeCloudControllerClient.DoStub = func(req *http.Request) (*http.Response, error) {
+								n := atomic.AddInt32(&inFlight, 1)
+								defer atomic.AddInt32(&inFlight, -1)
+
+								for {
+									max := atomic.LoadInt32(&maxInFlight)
+									if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+										break
+									}
+								}
+
+								time.Sleep(10 * time.Millisecond)
+								return generateApiResponse("some-body"), nil
+							}
+						})
+
+						It("fetches no more pages at the same time than allowed", func() {
+							Expect(err).NotTo(HaveOccurred())
+							Expect(responseBodies).To(HaveLen(6))
+							Expect(atomic.LoadInt32(&maxInFlight)).To(BeNumerically("<=", 3))
+						})
+					})
+
+					It("passes the params to every page without changing them", func() {
+						for i := 1; i < fakeRequestFactory.CallCount(); i++ {
+							_, pageParams := fakeRequestFactory.ArgsForCall(i)
+							Expect(pageParams["results-per-page"]).To(Equal(100))
+						}
+						Expect(params).To(Equal(map[string]interface{}{"results-per-page": 100}))
+					})
+
+					Context("when fetching one of the pages fails", func() {
+						BeforeEach(func() {
+							fakeCloudControllerClient.DoStub = func(req *http.Request) (*http.Response, error) {
+								if req.URL.Query().Get("page") == "4" {
+									return &http.Response{
+										StatusCode: http.StatusInternalServerError,
+										Body:       ioutil.NopCloser(strings.NewReader("")),
+									}, nil
+								}
+								return generateApiResponse("some-body"), nil
+							}
+						})
+
+						It("hands out the pages before it and returns the error", func() {
+							Expect(responseBodies).To(HaveLen(3))
+							Expect(err).To(Equal(api.CloudControllerError{StatusCode: http.StatusInternalServerError}))
+						})
+					})
+				})
 			})
 		})
 	})
 })
+
+var _ = Describe("PaginatedRequester following next links", func() {
+	var fakeCloudControllerClient *apifakes.FakeCloudControllerClient
+	var paginatedRequester *api.PaginatedRequester
+	var params map[string]interface{}
+	var bodies map[string]string
+
+	BeforeEach(func() {
+		params = map[string]interface{}{}
+		bodies = map[string]string{
+			"/v2/apps?results-per-page=2":        `{"total_pages":3,"next_url":"/v2/apps?page=2&results-per-page=2"}`,
+			"/v2/apps?page=2&results-per-page=2": `{"total_pages":3,"next_url":"/v2/apps?page=3&results-per-page=2"}`,
+			"/v2/apps?page=3&results-per-page=2": `{"total_pages":3,"next_url":null}`,
+		}
+
+		served := bodies
+		fakeCloudControllerClient = new(apifakes.FakeCloudControllerClient)
+		fakeCloudControllerClient.DoStub = func(req *http.Request) (*http.Response, error) {
+			body, ok := served[req.URL.RequestURI()]
+			if !ok {
+				return &http.Response{
+					StatusCode: http.StatusNotFound,
+					Body:       ioutil.NopCloser(strings.NewReader("")),
+				}, nil
+			}
+
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(strings.NewReader(body)),
+			}, nil
+		}
+
+		newRequest := func(link string) (*http.Request, error) {
+			return http.NewRequest("GET", "https://api.example.com"+link, nil)
+		}
+
+		paginatedRequester = api.NewPaginatedRequester(
+			fakeCloudControllerClient,
+			func(api.Filter, map[string]interface{}) (*http.Request, error) {
+				return newRequest("/v2/apps?results-per-page=2")
+			},
+			newRequest,
+		)
+	})
+
+	Describe("Pages", func() {
+		It("iterates over every page in order", func() {
+			pages := paginatedRequester.Pages(api.Filters{}, params)
+			defer pages.Close()
+
+			var got []string
+			for pages.Next() {
+				got = append(got, string(pages.Body()))
+			}
+
+			Expect(pages.Err()).NotTo(HaveOccurred())
+			Expect(got).To(Equal([]string{
+				bodies["/v2/apps?results-per-page=2"],
+				bodies["/v2/apps?page=2&results-per-page=2"],
+				bodies["/v2/apps?page=3&results-per-page=2"],
+			}))
+			Expect(params).To(BeEmpty())
+		})
+
+		It("stops at the first page that fails", func() {
+			delete(bodies, "/v2/apps?page=2&results-per-page=2")
+
+			pages := paginatedRequester.Pages(api.Filters{}, params)
+			defer pages.Close()
+
+			Expect(pages.Next()).To(BeTrue())
+			Expect(pages.Next()).To(BeFalse())
+			Expect(pages.Err()).To(Equal(api.CloudControllerError{StatusCode: http.StatusNotFound}))
+		})
+
+		It("stops fetching when closed early", func() {
+			pages := paginatedRequester.Pages(api.Filters{}, params)
+			Expect(pages.Next()).To(BeTrue())
+			pages.Close()
+
+			Consistently(fakeCloudControllerClient.DoCallCount).Should(BeNumerically("<=", 3))
+		})
+	})
+})
@@ -0,0 +1,167 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// RetryableError marks an error as transient, so that RetryPolicy.Do tries
+// the operation again.
+type RetryableError struct {
+	Err error
+}
+
+func (e RetryableError) Error() string {
+	return e.Err.Error()
+}
+
+type RetryPolicy struct {
+	MaxAttempts          int
+	Backoff              time.Duration
+	MaxBackoff           time.Duration
+	Jitter               float64
+	RetryableStatusCodes []int
+	RetryableErrorCodes  []string
+
+	Sleep func(time.Duration)
+}
+
+func (p RetryPolicy) RetryableStatus(statusCode int) bool {
+	for _, code := range p.RetryableStatusCodes {
+		if code == statusCode {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p RetryPolicy) RetryableErrorCode(errorCode string) bool {
+	for _, code := range p.RetryableErrorCodes {
+		if code == errorCode {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Delay returns how long to wait before the given retry, doubling the backoff
+// with every retry up to the maximum and spreading it by the jitter fraction.
+func (p RetryPolicy) Delay(retry int) time.Duration {
+	delay := p.Backoff
+	for i := 1; i < retry && (p.MaxBackoff == 0 || delay < p.MaxBackoff); i++ {
+		delay *= 2
+	}
+
+	if p.MaxBackoff != 0 && delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+
+	if p.Jitter > 0 {
+		spread := float64(delay) * p.Jitter
+		delay += time.Duration(spread * (2*rand.Float64() - 1))
+	}
+
+	return delay
+}
+
+// Do calls the operation until it succeeds, fails with an error that is not
+// a RetryableError, or all attempts are used up. onRetry is called before
+// every retry with the number of the attempt about to be made.
+func (p RetryPolicy) Do(operation func() error, onRetry func(attempt int, err error)) error {
+	sleep := p.Sleep
+	if sleep == nil {
+		sleep = time.Sleep
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := operation()
+
+		retryable, ok := err.(RetryableError)
+		if !ok {
+			return err
+		}
+
+		if attempt >= p.MaxAttempts {
+			return retryable.Err
+		}
+
+		if onRetry != nil {
+			onRetry(attempt+1, retryable.Err)
+		}
+
+		sleep(p.Delay(attempt))
+	}
+}
+
+// RetryingClient retries requests that fail, come back with a retryable
+// status code or with a Cloud Controller error with a retryable error code.
+// Once all attempts are used up the last response is returned.
+type RetryingClient struct {
+	Client CloudControllerClient
+	Policy RetryPolicy
+}
+
+func (c *RetryingClient) Do(req *http.Request) (*http.Response, error) {
+	var res *http.Response
+	retryableResponse := false
+
+	err := c.Policy.Do(func() error {
+		var err error
+		retryableResponse = false
+
+		res, err = c.Client.Do(req)
+		if err != nil {
+			return RetryableError{Err: err}
+		}
+
+		if c.Policy.RetryableStatus(res.StatusCode) {
+			retryableResponse = true
+			return RetryableError{Err: fmt.Errorf("%s %s returned %d", req.Method, req.URL.Path, res.StatusCode)}
+		}
+
+		if successful(res) || len(c.Policy.RetryableErrorCodes) == 0 {
+			return nil
+		}
+
+		ccErr, err := readCloudControllerError(res)
+		if err != nil {
+			return RetryableError{Err: err}
+		}
+
+		if c.Policy.RetryableErrorCode(ccErr.ErrorCode) {
+			retryableResponse = true
+			return RetryableError{Err: ccErr}
+		}
+
+		return nil
+	}, func(int, error) {
+		if res != nil {
+			res.Body.Close()
+		}
+	})
+
+	if err != nil && retryableResponse {
+		return res, nil
+	}
+
+	return res, err
+}
+
+// readCloudControllerError decodes the error in the body of the response and
+// leaves the body to be read again.
+func readCloudControllerError(res *http.Response) (CloudControllerError, error) {
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return CloudControllerError{}, err
+	}
+
+	return newCloudControllerError(res, body), nil
+}
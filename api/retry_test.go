@@ -0,0 +1,229 @@
+package api_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry-incubator/app-restarter/api"
+	"github.com/cloudfoundry-incubator/app-restarter/api/apifakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RetryPolicy", func() {
+	var policy api.RetryPolicy
+	var sleeps []time.Duration
+
+	BeforeEach(func() {
+		sleeps = nil
+		policy = api.RetryPolicy{
+			MaxAttempts:          3,
+			Backoff:              time.Second,
+			MaxBackoff:           3 * time.Second,
+			RetryableStatusCodes: []int{http.StatusBadGateway},
+			RetryableErrorCodes:  []string{"CF-RunnerUnavailable"},
+			Sleep: func(d time.Duration) {
+				sleeps = append(sleeps, d)
+			},
+		}
+	})
+
+	Describe("Delay", func() {
+		It("doubles the backoff up to the maximum", func() {
+			Expect(policy.Delay(1)).To(Equal(time.Second))
+			Expect(policy.Delay(2)).To(Equal(2 * time.Second))
+			Expect(policy.Delay(3)).To(Equal(3 * time.Second))
+			Expect(policy.Delay(10)).To(Equal(3 * time.Second))
+		})
+
+		It("spreads the backoff by the jitter", func() {
+			policy.Jitter = 0.5
+			for i := 0; i < 20; i++ {
+				Expect(policy.Delay(1)).To(BeNumerically(">=", 500*time.Millisecond))
+				Expect(policy.Delay(1)).To(BeNumerically("<=", 1500*time.Millisecond))
+			}
+		})
+	})
+
+	Describe("RetryableStatus and RetryableErrorCode", func() {
+		It("matches the configured codes", func() {
+			Expect(policy.RetryableStatus(http.StatusBadGateway)).To(BeTrue())
+			Expect(policy.RetryableStatus(http.StatusNotFound)).To(BeFalse())
+			Expect(policy.RetryableErrorCode("CF-RunnerUnavailable")).To(BeTrue())
+			Expect(policy.RetryableErrorCode("CF-NotAuthorized")).To(BeFalse())
+		})
+	})
+
+	Describe("Do", func() {
+		var calls int
+		var retries []int
+
+		onRetry := func(attempt int, err error) {
+			retries = append(retries, attempt)
+		}
+
+		BeforeEach(func() {
+			calls = 0
+			retries = nil
+		})
+
+		It("retries retryable errors until the operation succeeds", func() {
+			err := policy.Do(func() error {
+				calls++
+				if calls < 3 {
+					return api.RetryableError{Err: errors.New("flaky")}
+				}
+				return nil
+			}, onRetry)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(calls).To(Equal(3))
+			Expect(retries).To(Equal([]int{2, 3}))
+			Expect(sleeps).To(Equal([]time.Duration{time.Second, 2 * time.Second}))
+		})
+
+		It("returns the last error once all attempts are used up", func() {
+			disaster := errors.New("still flaky")
+			err := policy.Do(func() error {
+				calls++
+				return api.RetryableError{Err: disaster}
+			}, onRetry)
+
+			Expect(err).To(Equal(disaster))
+			Expect(calls).To(Equal(3))
+		})
+
+		It("does not retry other errors", func() {
+			disaster := errors.New("OH NOOOOOOO")
+			err := policy.Do(func() error {
+				calls++
+				return disaster
+			}, onRetry)
+
+			Expect(err).To(Equal(disaster))
+			Expect(calls).To(Equal(1))
+			Expect(retries).To(BeEmpty())
+		})
+	})
+
+	Describe("RetryingClient", func() {
+		var fakeCloudControllerClient *apifakes.FakeCloudControllerClient
+		var client *api.RetryingClient
+		var req *http.Request
+
+		response := func(statusCode int) *http.Response {
+			return &http.Response{
+				StatusCode: statusCode,
+				Body:       ioutil.NopCloser(strings.NewReader("")),
+			}
+		}
+
+		BeforeEach(func() {
+			var err error
+			req, err = http.NewRequest("GET", "https://api.example.com/v2/apps", nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			fakeCloudControllerClient = new(apifakes.FakeCloudControllerClient)
+			client = &api.RetryingClient{Client: fakeCloudControllerClient, Policy: policy}
+		})
+
+		It("retries requests that come back with a retryable status", func() {
+			fakeCloudControllerClient.DoStub = func(*http.Request) (*http.Response, error) {
+				if fakeCloudControllerClient.DoCallCount() == 1 {
+					return response(http.StatusBadGateway), nil
+				}
+				return response(http.StatusOK), nil
+			}
+
+			res, err := client.Do(req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res.StatusCode).To(Equal(http.StatusOK))
+			Expect(fakeCloudControllerClient.DoCallCount()).To(Equal(2))
+		})
+
+		It("retries requests that fail", func() {
+			fakeCloudControllerClient.DoStub = func(*http.Request) (*http.Response, error) {
+				if fakeCloudControllerClient.DoCallCount() == 1 {
+					return nil, errors.New("connection reset")
+				}
+				return response(http.StatusOK), nil
+			}
+
+			res, err := client.Do(req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res.StatusCode).To(Equal(http.StatusOK))
+		})
+
+		It("returns the last response once all attempts are used up", func() {
+			fakeCloudControllerClient.DoReturns(response(http.StatusBadGateway), nil)
+
+			res, err := client.Do(req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res.StatusCode).To(Equal(http.StatusBadGateway))
+			Expect(fakeCloudControllerClient.DoCallCount()).To(Equal(3))
+		})
+
+		Context("when the Cloud Controller responds with an error", func() {
+			errorResponse := func(errorCode string) *http.Response {
+				return &http.Response{
+					StatusCode: http.StatusServiceUnavailable,
+					Body:       ioutil.NopCloser(strings.NewReader(`{"code":170015,"description":"Runner is unavailable","error_code":"` + errorCode + `"}`)),
+				}
+			}
+
+			It("retries requests that come back with a retryable error code", func() {
+				fakeCloudControllerClient.DoStub = func(*http.Request) (*http.Response, error) {
+					if fakeCloudControllerClient.DoCallCount() == 1 {
+						return errorResponse("CF-RunnerUnavailable"), nil
+					}
+					return response(http.StatusOK), nil
+				}
+
+				res, err := client.Do(req)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(res.StatusCode).To(Equal(http.StatusOK))
+				Expect(fakeCloudControllerClient.DoCallCount()).To(Equal(2))
+			})
+
+			It("returns the last response with its body once all attempts are used up", func() {
+				fakeCloudControllerClient.DoStub = func(*http.Request) (*http.Response, error) {
+					return errorResponse("CF-RunnerUnavailable"), nil
+				}
+
+				res, err := client.Do(req)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(res.StatusCode).To(Equal(http.StatusServiceUnavailable))
+				Expect(fakeCloudControllerClient.DoCallCount()).To(Equal(3))
+
+				body, err := ioutil.ReadAll(res.Body)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(body)).To(ContainSubstring("CF-RunnerUnavailable"))
+			})
+
+			It("does not retry other error codes", func() {
+				fakeCloudControllerClient.DoReturns(errorResponse("CF-AppNotFound"), nil)
+
+				res, err := client.Do(req)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(res.StatusCode).To(Equal(http.StatusServiceUnavailable))
+				Expect(fakeCloudControllerClient.DoCallCount()).To(Equal(1))
+
+				body, err := ioutil.ReadAll(res.Body)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(body)).To(ContainSubstring("CF-AppNotFound"))
+			})
+		})
+
+		It("does not retry other statuses", func() {
+			fakeCloudControllerClient.DoReturns(response(http.StatusNotFound), nil)
+
+			res, err := client.Do(req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res.StatusCode).To(Equal(http.StatusNotFound))
+			Expect(fakeCloudControllerClient.DoCallCount()).To(Equal(1))
+		})
+	})
+})
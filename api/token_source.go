@@ -0,0 +1,116 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenExpiryMargin is how long before its expiry a token is replaced, so
+// that it does not expire while a request is in flight.
+const tokenExpiryMargin = time.Minute
+
+// TokenSource hands out the access token of the CLI. The token is fetched
+// again from the connection, which refreshes it with UAA, shortly before it
+// expires or when the Cloud Controller rejected it.
+type TokenSource struct {
+	connection Connection
+
+	lock   sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+func NewTokenSource(connection Connection) *TokenSource {
+	return &TokenSource{connection: connection}
+}
+
+func (s *TokenSource) Token() (string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.token != "" && (s.expiry.IsZero() || time.Now().Add(tokenExpiryMargin).Before(s.expiry)) {
+		return s.token, nil
+	}
+
+	return s.fetch()
+}
+
+func (s *TokenSource) Refresh() (string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.fetch()
+}
+
+func (s *TokenSource) fetch() (string, error) {
+	token, err := s.connection.AccessToken()
+	if err != nil {
+		return "", err
+	}
+
+	s.token = token
+	s.expiry = tokenExpiry(token)
+
+	return token, nil
+}
+
+// tokenExpiry reads the exp claim of a JWT bearer token. The zero time is
+// returned for tokens that cannot be decoded, which are then never replaced
+// before the Cloud Controller rejects them.
+func tokenExpiry(token string) time.Time {
+	token = strings.TrimSpace(token)
+	if i := strings.Index(token, " "); i != -1 {
+		token = token[i+1:]
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}
+	}
+
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if json.Unmarshal(payload, &claims) != nil || claims.Exp == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(claims.Exp, 0)
+}
+
+// ReauthorizingClient sends a request once more with a refreshed token when
+// the Cloud Controller responds with 401 Unauthorized.
+type ReauthorizingClient struct {
+	Client CloudControllerClient
+	Tokens *TokenSource
+}
+
+func (c *ReauthorizingClient) Do(req *http.Request) (*http.Response, error) {
+	res, err := c.Client.Do(req)
+	if err != nil || res.StatusCode != http.StatusUnauthorized {
+		return res, err
+	}
+
+	token, err := c.Tokens.Refresh()
+	if err != nil {
+		return res, nil
+	}
+
+	res.Body.Close()
+
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+	req.Header.Set("Authorization", token)
+
+	return c.Client.Do(req)
+}
@@ -0,0 +1,130 @@
+package api_test
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry-incubator/app-restarter/api"
+	"github.com/cloudfoundry-incubator/app-restarter/api/apifakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TokenSource", func() {
+	var cliConnection *apifakes.FakeConnection
+	var tokens *api.TokenSource
+
+	jwt := func(name string, expiry time.Time) string {
+		claims := fmt.Sprintf(`{"user_name":%q,"exp":%d}`, name, expiry.Unix())
+		return "bearer header." + base64.RawURLEncoding.EncodeToString([]byte(claims)) + ".signature"
+	}
+
+	BeforeEach(func() {
+		cliConnection = new(apifakes.FakeConnection)
+		tokens = api.NewTokenSource(cliConnection)
+	})
+
+	Describe("Token", func() {
+		It("fetches the token from the connection once while it is valid", func() {
+			first := jwt("first", time.Now().Add(time.Hour))
+			cliConnection.AccessTokenReturns(first, nil)
+
+			token, err := tokens.Token()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(token).To(Equal(first))
+
+			_, err = tokens.Token()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cliConnection.AccessTokenCallCount()).To(Equal(1))
+		})
+
+		It("fetches the token again shortly before it expires", func() {
+			cliConnection.AccessTokenReturns(jwt("first", time.Now().Add(10*time.Second)), nil)
+
+			_, err := tokens.Token()
+			Expect(err).NotTo(HaveOccurred())
+
+			second := jwt("second", time.Now().Add(time.Hour))
+			cliConnection.AccessTokenReturns(second, nil)
+
+			token, err := tokens.Token()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(token).To(Equal(second))
+			Expect(cliConnection.AccessTokenCallCount()).To(Equal(2))
+		})
+
+		It("keeps tokens that cannot be decoded", func() {
+			cliConnection.AccessTokenReturns("some-auth-token", nil)
+
+			tokens.Token()
+			tokens.Token()
+			Expect(cliConnection.AccessTokenCallCount()).To(Equal(1))
+		})
+	})
+
+	Describe("Refresh", func() {
+		It("always fetches the token from the connection", func() {
+			cliConnection.AccessTokenReturns("some-auth-token", nil)
+
+			tokens.Token()
+			token, err := tokens.Refresh()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(token).To(Equal("some-auth-token"))
+			Expect(cliConnection.AccessTokenCallCount()).To(Equal(2))
+		})
+	})
+
+	Describe("ReauthorizingClient", func() {
+		var fakeCloudControllerClient *apifakes.FakeCloudControllerClient
+		var client *api.ReauthorizingClient
+		var req *http.Request
+
+		response := func(statusCode int) *http.Response {
+			return &http.Response{
+				StatusCode: statusCode,
+				Body:       ioutil.NopCloser(strings.NewReader("")),
+			}
+		}
+
+		BeforeEach(func() {
+			var err error
+			req, err = http.NewRequest("GET", "https://api.example.com/v2/apps", nil)
+			Expect(err).NotTo(HaveOccurred())
+			req.Header.Set("Authorization", "expired-token")
+
+			cliConnection.AccessTokenReturns("fresh-token", nil)
+
+			fakeCloudControllerClient = new(apifakes.FakeCloudControllerClient)
+			client = &api.ReauthorizingClient{Client: fakeCloudControllerClient, Tokens: tokens}
+		})
+
+		It("sends the request again with a refreshed token after a 401", func() {
+			fakeCloudControllerClient.DoStub = func(*http.Request) (*http.Response, error) {
+				if fakeCloudControllerClient.DoCallCount() == 1 {
+					return response(http.StatusUnauthorized), nil
+				}
+				return response(http.StatusOK), nil
+			}
+
+			res, err := client.Do(req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res.StatusCode).To(Equal(http.StatusOK))
+			Expect(fakeCloudControllerClient.DoCallCount()).To(Equal(2))
+			Expect(fakeCloudControllerClient.DoArgsForCall(1).Header.Get("Authorization")).To(Equal("fresh-token"))
+		})
+
+		It("does not touch other responses", func() {
+			fakeCloudControllerClient.DoReturns(response(http.StatusForbidden), nil)
+
+			res, err := client.Do(req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res.StatusCode).To(Equal(http.StatusForbidden))
+			Expect(fakeCloudControllerClient.DoCallCount()).To(Equal(1))
+			Expect(cliConnection.AccessTokenCallCount()).To(Equal(0))
+		})
+	})
+})
@@ -0,0 +1,32 @@
+package commands
+
+import (
+	"bufio"
+	"io"
+	"os"
+)
+
+// readAppsFile reads the lines of the file listing the apps to restart, or
+// of stdin when the path is "-".
+func readAppsFile(path string) ([]string, error) {
+	var reader io.Reader = os.Stdin
+
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+
+		reader = file
+	}
+
+	var lines []string
+
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+
+	return lines, scanner.Err()
+}
@@ -0,0 +1,79 @@
+package commands
+
+import (
+	"context"
+	"time"
+
+	"github.com/cloudfoundry-incubator/app-restarter/commands/errorhelpers"
+	"github.com/cloudfoundry-incubator/app-restarter/models"
+	"github.com/cloudfoundry-incubator/app-restarter/ui"
+)
+
+// restartWithCanaries restarts the canary apps first and only goes on with
+// the remaining apps once every canary is healthy again and the wait is
+// over or the user confirmed.
+func (exe *RestartAppsExecutor) restartWithCanaries(
+	ctx context.Context,
+	restarter AppRestarter,
+	apps models.Applications,
+	spaceMap map[string]models.Space,
+) ([]appResult, ui.RestartSummary, error) {
+	canaries, remainder := exe.splitCanaries(apps)
+
+	exe.RestartAppsUI.BeforeCanaries(len(canaries))
+	results, summary, err := exe.restartApps(ctx, restarter, canaries, spaceMap)
+
+	if err == nil && ctx.Err() != nil {
+		err = errorhelpers.InterruptedError
+	}
+	if err == nil && summary.Successes() != len(canaries) {
+		err = errorhelpers.CanaryFailedError
+	}
+
+	if err != nil {
+		results, summary = appendNotAttempted(results, summary, remainder, spaceMap)
+		return results, summary, err
+	}
+
+	if len(remainder) == 0 {
+		return results, summary, nil
+	}
+
+	if exe.CanaryWait > 0 {
+		exe.RestartAppsUI.WaitAfterCanaries(exe.CanaryWait, len(remainder))
+
+		select {
+		case <-time.After(exe.CanaryWait):
+		case <-ctx.Done():
+			results, summary = appendNotAttempted(results, summary, remainder, spaceMap)
+			return results, summary, errorhelpers.InterruptedError
+		}
+	} else if !exe.RestartAppsUI.ConfirmAfterCanaries(ctx, len(remainder)) {
+		results, summary = appendNotAttempted(results, summary, remainder, spaceMap)
+		if ctx.Err() != nil {
+			return results, summary, errorhelpers.InterruptedError
+		}
+		return results, summary, errorhelpers.CanaryAbortedError
+	}
+
+	remainderResults, remainderSummary, err := exe.restartApps(ctx, restarter, remainder, spaceMap)
+
+	return append(results, remainderResults...), summary.Add(remainderSummary), err
+}
+
+// splitCanaries picks the first apps that would actually be restarted as the
+// canaries. Stopped apps and apps already restarted by a previous run stay
+// with the remainder, where they are skipped as usual.
+func (exe *RestartAppsExecutor) splitCanaries(apps models.Applications) (models.Applications, models.Applications) {
+	var canaries, remainder models.Applications
+
+	for _, app := range apps {
+		if exe.restartable(app) && len(canaries) < exe.Canary {
+			canaries = append(canaries, app)
+		} else {
+			remainder = append(remainder, app)
+		}
+	}
+
+	return canaries, remainder
+}
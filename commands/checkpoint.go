@@ -0,0 +1,134 @@
+package commands
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/app-restarter/commands/errorhelpers"
+)
+
+type checkpointRecord struct {
+	Outcome     string    `json:"outcome"`
+	CompletedAt time.Time `json:"completed_at"`
+}
+
+// Checkpoint records the outcome of every app as it completes, so that an
+// interrupted run can be resumed without restarting those apps again.
+type Checkpoint struct {
+	path     string
+	readOnly bool
+	lock     sync.Mutex
+
+	Apps map[string]checkpointRecord `json:"apps"`
+}
+
+// NewCheckpoint refuses to start over on an existing state file unless the
+// run resumes from it, so that the record of an interrupted run is kept.
+func NewCheckpoint(path string, resume bool) (*Checkpoint, error) {
+	_, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		checkpoint := &Checkpoint{
+			path: path,
+			Apps: map[string]checkpointRecord{},
+		}
+		return checkpoint, checkpoint.save()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !resume {
+		return nil, errorhelpers.StateFileExistsError
+	}
+
+	return loadCheckpoint(path, false)
+}
+
+// ReadCheckpoint opens the state file read-only, for a dry run that shows
+// which apps a resumed run would skip. A missing state file is taken as one
+// without any apps and is not created.
+func ReadCheckpoint(path string) (*Checkpoint, error) {
+	return loadCheckpoint(path, true)
+}
+
+func loadCheckpoint(path string, readOnly bool) (*Checkpoint, error) {
+	checkpoint := &Checkpoint{
+		path:     path,
+		readOnly: readOnly,
+		Apps:     map[string]checkpointRecord{},
+	}
+
+	body, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) && readOnly {
+		return checkpoint, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(body, checkpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	if checkpoint.Apps == nil {
+		checkpoint.Apps = map[string]checkpointRecord{}
+	}
+
+	return checkpoint, nil
+}
+
+func (c *Checkpoint) Restarted(appGuid string) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.Apps[appGuid].Outcome == outcomeName(Success)
+}
+
+func (c *Checkpoint) Record(appGuid string, outcome int) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.Apps[appGuid] = checkpointRecord{
+		Outcome:     outcomeName(outcome),
+		CompletedAt: time.Now().UTC(),
+	}
+
+	return c.save()
+}
+
+// save replaces the state file atomically so that it is never left half
+// written when the run dies. A read-only checkpoint is only kept in memory.
+func (c *Checkpoint) save() error {
+	if c.readOnly {
+		return nil
+	}
+
+	body, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmpPath := c.path + ".tmp"
+	file, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	_, err = file.Write(body)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	return os.Rename(tmpPath, c.path)
+}
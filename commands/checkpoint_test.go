@@ -0,0 +1,153 @@
+package commands_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/cloudfoundry-incubator/app-restarter/commands"
+	"github.com/cloudfoundry-incubator/app-restarter/commands/errorhelpers"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Checkpoint", func() {
+	var (
+		dir  string
+		path string
+	)
+
+	type stateFile struct {
+		Apps map[string]struct {
+			Outcome     string `json:"outcome"`
+			CompletedAt string `json:"completed_at"`
+		} `json:"apps"`
+	}
+
+	readStateFile := func() stateFile {
+		body, err := ioutil.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+
+		var state stateFile
+		Expect(json.Unmarshal(body, &state)).To(Succeed())
+		return state
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "checkpoint")
+		Expect(err).NotTo(HaveOccurred())
+
+		path = filepath.Join(dir, "state.json")
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("creates the state file when there is none", func() {
+		checkpoint, err := commands.NewCheckpoint(path, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(checkpoint.Restarted("app-guid")).To(BeFalse())
+
+		Expect(readStateFile().Apps).To(BeEmpty())
+	})
+
+	It("refuses to start over on an existing state file without resume", func() {
+		Expect(ioutil.WriteFile(path, []byte(`{"apps":{}}`), 0600)).To(Succeed())
+
+		_, err := commands.NewCheckpoint(path, false)
+		Expect(err).To(Equal(errorhelpers.StateFileExistsError))
+
+		body, err := ioutil.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(body)).To(Equal(`{"apps":{}}`))
+	})
+
+	It("records the outcome of every app and resumes from it", func() {
+		checkpoint, err := commands.NewCheckpoint(path, false)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(checkpoint.Record("restarted-guid", commands.Success)).To(Succeed())
+		Expect(checkpoint.Record("crashed-guid", commands.Crashed)).To(Succeed())
+		Expect(checkpoint.Restarted("restarted-guid")).To(BeTrue())
+		Expect(checkpoint.Restarted("crashed-guid")).To(BeFalse())
+
+		state := readStateFile()
+		Expect(state.Apps).To(HaveLen(2))
+		Expect(state.Apps["restarted-guid"].Outcome).To(Equal("Success"))
+		Expect(state.Apps["restarted-guid"].CompletedAt).NotTo(BeEmpty())
+		Expect(state.Apps["crashed-guid"].Outcome).To(Equal("Crashed"))
+
+		resumed, err := commands.NewCheckpoint(path, true)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resumed.Restarted("restarted-guid")).To(BeTrue())
+		Expect(resumed.Restarted("crashed-guid")).To(BeFalse())
+		Expect(resumed.Restarted("other-guid")).To(BeFalse())
+	})
+
+	It("resumes from a state file without apps", func() {
+		Expect(ioutil.WriteFile(path, []byte(`{}`), 0600)).To(Succeed())
+
+		checkpoint, err := commands.NewCheckpoint(path, true)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(checkpoint.Record("app-guid", commands.Success)).To(Succeed())
+	})
+
+	It("returns an error for a state file that is not JSON", func() {
+		Expect(ioutil.WriteFile(path, []byte(`not json`), 0600)).To(Succeed())
+
+		_, err := commands.NewCheckpoint(path, true)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("replaces the state file without leaving the temporary file behind", func() {
+		checkpoint, err := commands.NewCheckpoint(path, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(checkpoint.Record("app-guid", commands.Success)).To(Succeed())
+
+		_, err = os.Stat(path + ".tmp")
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+
+	It("keeps the previous state file when the new one cannot be written", func() {
+		checkpoint, err := commands.NewCheckpoint(path, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(checkpoint.Record("first-guid", commands.Success)).To(Succeed())
+
+		Expect(os.Mkdir(path+".tmp", 0700)).To(Succeed())
+		Expect(checkpoint.Record("second-guid", commands.Success)).NotTo(Succeed())
+
+		state := readStateFile()
+		Expect(state.Apps).To(HaveLen(1))
+		Expect(state.Apps).To(HaveKey("first-guid"))
+	})
+
+	Describe("ReadCheckpoint", func() {
+		It("does not create a missing state file", func() {
+			checkpoint, err := commands.ReadCheckpoint(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(checkpoint.Restarted("app-guid")).To(BeFalse())
+
+			_, err = os.Stat(path)
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+
+		It("loads the recorded apps without ever writing the state file", func() {
+			body := []byte(`{"apps":{"app-guid":{"outcome":"Success"}}}`)
+			Expect(ioutil.WriteFile(path, body, 0600)).To(Succeed())
+
+			checkpoint, err := commands.ReadCheckpoint(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(checkpoint.Restarted("app-guid")).To(BeTrue())
+
+			Expect(checkpoint.Record("other-guid", commands.Success)).To(Succeed())
+
+			written, err := ioutil.ReadFile(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(written).To(Equal(body))
+		})
+	})
+})
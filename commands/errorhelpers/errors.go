@@ -1,12 +1,105 @@
 package errorhelpers
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"time"
+)
 
-var SpecifyOrgOrSpaceError = errors.New("Cannot specify org together with space.")
+var ResumeWithoutStateFileError = errors.New("Cannot resume without a state file.")
+var StateFileExistsError = errors.New("The state file already exists, use --resume to continue the interrupted run or remove the file to start over.")
+var AppsFileWithOrgOrSpaceError = errors.New("Cannot specify an apps file together with org or space.")
+var ConfirmWithAppsFromStdinError = errors.New("Cannot ask for confirmation while reading the apps file from stdin, use --force.")
+var NotConfirmedError = errors.New("Not confirmed, no apps were restarted.")
 
-func ErrorIfOrgAndSpacesSet(orgName, spaceName string) error {
-	if orgName != "" && spaceName != "" {
-		return SpecifyOrgOrSpaceError
+func ErrorIfResumeWithoutStateFile(resume bool, stateFile string) error {
+	if resume && stateFile == "" {
+		return ResumeWithoutStateFileError
+	}
+	return nil
+}
+
+func ErrorIfAppsFileAndOrgOrSpaceSet(appsFile string, orgNames, spaceNames []string) error {
+	if appsFile != "" && (len(orgNames) > 0 || len(spaceNames) > 0) {
+		return AppsFileWithOrgOrSpaceError
+	}
+	return nil
+}
+
+var CanaryWaitWithoutCanaryError = errors.New("Cannot specify a canary wait without canary apps.")
+var CanaryFailedError = errors.New("Not all canary apps restarted successfully, the remaining apps were not restarted.")
+var CanaryAbortedError = errors.New("The remaining apps were not restarted.")
+var InterruptedError = errors.New("Interrupted, the remaining apps were not restarted.")
+var FailureThresholdError = errors.New("Too many apps failed to restart, the remaining apps were not restarted.")
+var WindowClosedError = errors.New("The maintenance window closed, the remaining apps were not restarted.")
+var InvalidWindowError = errors.New("Invalid window, expected START-END as HH:MM-HH:MM.")
+var InvalidTimezoneError = errors.New("Invalid timezone, expected a name like Europe/Berlin.")
+var WindowExitWithoutStateFileError = errors.New("Cannot exit outside the window without a state file to resume from.")
+var WindowOptionsWithoutWindowError = errors.New("Cannot specify a timezone or exit outside the window without a window.")
+var InvalidRateError = errors.New("Invalid rate, expected N/min, N/s or N/h.")
+var RollingRestageError = errors.New("Cannot restage apps with a rolling restart.")
+var ReverseWithoutOrderByError = errors.New("Cannot reverse the order of the apps without an order.")
+
+// RestartFailedError is returned once all apps are done when any of them
+// failed to restart, so that the command exits non-zero.
+type RestartFailedError struct {
+	Errors        int
+	Crashed       int
+	TimedOut      int
+	StagingFailed int
+	Warnings      int
+}
+
+func (e RestartFailedError) Error() string {
+	return fmt.Sprintf(
+		"%d apps failed to restart: %d errors, %d crashed, %d timed out, %d failed to stage, %d warnings.",
+		e.Errors+e.Crashed+e.TimedOut+e.StagingFailed+e.Warnings,
+		e.Errors,
+		e.Crashed,
+		e.TimedOut,
+		e.StagingFailed,
+		e.Warnings,
+	)
+}
+
+func ErrorIfRollingRestage(rolling bool, strategy string) error {
+	if rolling && strategy == "restage" {
+		return RollingRestageError
+	}
+	return nil
+}
+
+func ErrorIfConfirmWithAppsFromStdin(appsFile string, force bool) error {
+	if appsFile == "-" && !force {
+		return ConfirmWithAppsFromStdinError
+	}
+	return nil
+}
+
+func ErrorIfWindowOptionsWithoutWindow(window string, timezone string, exitOutsideWindow bool) error {
+	if window == "" && (timezone != "" || exitOutsideWindow) {
+		return WindowOptionsWithoutWindowError
+	}
+	return nil
+}
+
+func ErrorIfWindowExitWithoutStateFile(exitOutsideWindow bool, stateFile string) error {
+	if exitOutsideWindow && stateFile == "" {
+		return WindowExitWithoutStateFileError
+	}
+	return nil
+}
+
+func ErrorIfCanaryWaitWithoutCanary(canary int, canaryWait time.Duration) error {
+	if canaryWait > 0 && canary < 1 {
+		return CanaryWaitWithoutCanaryError
+	}
+	return nil
+}
+
+func ErrorIfReverseWithoutOrderBy(reverse bool, orderBy string) error {
+	if reverse && orderBy == "" {
+		return ReverseWithoutOrderByError
 	}
 	return nil
 }
@@ -0,0 +1,113 @@
+package commands
+
+import (
+	"github.com/cloudfoundry-incubator/app-restarter/commands/displayhelpers"
+	"github.com/cloudfoundry-incubator/app-restarter/commands/errorhelpers"
+	"github.com/cloudfoundry-incubator/app-restarter/models"
+	"github.com/cloudfoundry-incubator/app-restarter/ui"
+)
+
+// failureThresholdReached reports whether so many of the apps failed that no
+// more restarts should be started. The percentage is of all apps in the run,
+// so that a failure among the first few apps does not stop it.
+func (exe *RestartAppsExecutor) failureThresholdReached(summary ui.RestartSummary, apps int) bool {
+	failures := summary.Failures()
+
+	if exe.MaxFailures > 0 && failures >= exe.MaxFailures {
+		return true
+	}
+
+	if exe.MaxFailurePercent > 0 && float64(failures)*100 >= exe.MaxFailurePercent*float64(apps) {
+		return true
+	}
+
+	return false
+}
+
+// appendNotAttempted adds a NotAttempted result for every app without a
+// result, i.e. that was never dispatched.
+func appendNotAttempted(
+	results []appResult,
+	summary ui.RestartSummary,
+	apps models.Applications,
+	spaceMap map[string]models.Space,
+) ([]appResult, ui.RestartSummary) {
+	attempted := map[string]bool{}
+	for _, result := range results {
+		attempted[result.App.App.Guid] = true
+	}
+
+	for _, app := range apps {
+		if attempted[app.Guid] {
+			continue
+		}
+
+		results = append(results, appResult{
+			App: &displayhelpers.AppPrinter{
+				App:    app,
+				Spaces: spaceMap,
+			},
+			Outcome: NotAttempted,
+		})
+		summary.Attempts++
+		summary.NotAttempted++
+	}
+
+	return results, summary
+}
+
+func notAttemptedApps(results []appResult) []ui.ApplicationPrinter {
+	var apps []ui.ApplicationPrinter
+	for _, result := range results {
+		if result.Outcome == NotAttempted {
+			apps = append(apps, result.App)
+		}
+	}
+
+	return apps
+}
+
+// restartFailedError returns a RestartFailedError when any app failed to
+// restart, including those that could not be restarted for lack of
+// authorization when FailOnWarning is set.
+func (exe *RestartAppsExecutor) restartFailedError(summary ui.RestartSummary) error {
+	failed := errorhelpers.RestartFailedError{
+		Errors:        summary.Errors,
+		Crashed:       summary.Crashed,
+		TimedOut:      summary.TimedOut,
+		StagingFailed: summary.StagingFailed,
+	}
+	if exe.FailOnWarning {
+		failed.Warnings = summary.Warnings
+	}
+
+	if failed.Errors+failed.Crashed+failed.TimedOut+failed.StagingFailed+failed.Warnings == 0 {
+		return nil
+	}
+
+	return failed
+}
+
+func (exe *RestartAppsExecutor) failedApps(results []appResult) []ui.FailedApp {
+	var failed []ui.FailedApp
+	for _, result := range results {
+		reason := ""
+		switch {
+		case result.Outcome == Crashed:
+			reason = "App crashed after restarting"
+		case result.Outcome == TimedOut:
+			reason = "App did not start in time"
+		case result.Outcome == Err, result.Outcome == StagingFailed, result.Outcome == Warning && exe.FailOnWarning:
+			reason = "Failed to restart app"
+			if result.Err != nil {
+				reason = result.Err.Error()
+			}
+		default:
+			continue
+		}
+
+		failed = append(failed, ui.FailedApp{App: result.App, Reason: reason})
+	}
+
+	return failed
+}
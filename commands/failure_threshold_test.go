@@ -0,0 +1,84 @@
+package commands
+
+import (
+	"github.com/cloudfoundry-incubator/app-restarter/commands/displayhelpers"
+	"github.com/cloudfoundry-incubator/app-restarter/models"
+	"github.com/cloudfoundry-incubator/app-restarter/ui"
+
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("FailureThreshold", func() {
+	table.DescribeTable("failureThresholdReached",
+		func(exe RestartAppsExecutor, summary ui.RestartSummary, apps int, expected bool) {
+			Expect(exe.failureThresholdReached(summary, apps)).To(Equal(expected))
+		},
+		table.Entry("is never reached without a threshold",
+			RestartAppsExecutor{}, ui.RestartSummary{Errors: 10}, 10, false),
+		table.Entry("is not reached below the count",
+			RestartAppsExecutor{MaxFailures: 3}, ui.RestartSummary{Errors: 1, Crashed: 1}, 10, false),
+		table.Entry("is reached at the count",
+			RestartAppsExecutor{MaxFailures: 3}, ui.RestartSummary{Errors: 1, Crashed: 1, TimedOut: 1}, 10, true),
+		table.Entry("counts failed staging",
+			RestartAppsExecutor{MaxFailures: 1}, ui.RestartSummary{StagingFailed: 1}, 10, true),
+		table.Entry("does not count warnings, stopped, skipped or apps not attempted",
+			RestartAppsExecutor{MaxFailures: 1}, ui.RestartSummary{Warnings: 1, Stopped: 1, Skipped: 1, NotAttempted: 1}, 10, false),
+		table.Entry("is not reached below the percentage of all apps",
+			RestartAppsExecutor{MaxFailurePercent: 10}, ui.RestartSummary{Attempts: 1, Errors: 1}, 20, false),
+		table.Entry("is reached at the percentage of all apps",
+			RestartAppsExecutor{MaxFailurePercent: 10}, ui.RestartSummary{Attempts: 2, Errors: 2}, 20, true),
+		table.Entry("is reached at either threshold",
+			RestartAppsExecutor{MaxFailures: 5, MaxFailurePercent: 10}, ui.RestartSummary{Errors: 2}, 20, true),
+	)
+
+	ginkgo.Describe("appendNotAttempted", func() {
+		app := func(name string) models.Application {
+			application := models.Application{}
+			application.Name = name
+			application.Guid = name + "-guid"
+			return application
+		}
+
+		apps := models.Applications{app("a"), app("b"), app("c")}
+
+		result := func(application models.Application, outcome int) appResult {
+			return appResult{
+				App:     &displayhelpers.AppPrinter{App: application},
+				Outcome: outcome,
+			}
+		}
+
+		outcomes := func(results []appResult) map[string]int {
+			byName := map[string]int{}
+			for _, r := range results {
+				byName[r.App.App.Name] = r.Outcome
+			}
+			return byName
+		}
+
+		ginkgo.It("adds a NotAttempted result for every app without a result", func() {
+			results := []appResult{result(apps[1], Crashed)}
+			summary := ui.RestartSummary{Attempts: 1, Crashed: 1}
+
+			results, summary = appendNotAttempted(results, summary, apps, nil)
+
+			Expect(results).To(HaveLen(3))
+			Expect(outcomes(results)).To(Equal(map[string]int{"a": NotAttempted, "b": Crashed, "c": NotAttempted}))
+			Expect(summary).To(Equal(ui.RestartSummary{Attempts: 3, Crashed: 1, NotAttempted: 2}))
+			Expect(notAttemptedApps(results)).To(HaveLen(2))
+		})
+
+		ginkgo.It("adds nothing once every app has a result", func() {
+			results := []appResult{result(apps[0], Success), result(apps[1], Stopped), result(apps[2], Skipped)}
+			summary := ui.RestartSummary{Attempts: 3, Stopped: 1, Skipped: 1}
+
+			newResults, newSummary := appendNotAttempted(results, summary, apps, nil)
+
+			Expect(newResults).To(Equal(results))
+			Expect(newSummary).To(Equal(summary))
+			Expect(notAttemptedApps(newResults)).To(BeEmpty())
+		})
+	})
+})
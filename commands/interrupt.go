@@ -0,0 +1,46 @@
+package commands
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/cloudfoundry-incubator/app-restarter/ui"
+)
+
+// interruptContext returns a context that is cancelled on the first SIGINT
+// or SIGTERM, so that no further apps are restarted while the restarts in
+// flight are finished. A second signal exits right away.
+func interruptContext(restartAppsUI *ui.RestartApps) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-signals:
+		case <-done:
+			return
+		}
+
+		restartAppsUI.Interrupted()
+		cancel()
+
+		select {
+		case <-signals:
+			os.Exit(130)
+		case <-done:
+		}
+	}()
+
+	stop := func() {
+		signal.Stop(signals)
+		close(done)
+		cancel()
+	}
+
+	return ctx, stop
+}
@@ -0,0 +1,68 @@
+package commands
+
+import (
+	"net/http"
+
+	"github.com/cloudfoundry-incubator/app-restarter/api"
+	"github.com/cloudfoundry-incubator/app-restarter/models"
+	"github.com/cloudfoundry-incubator/app-restarter/resource_mapper"
+)
+
+// newLookups fetches single resources through the same client as the
+// listings, so that they are retried and their errors reported alike.
+func newLookups(apiClient *api.Client, ccClient api.CloudControllerClient) resource_mapper.Lookups {
+	get := func(req *http.Request, err error) ([]byte, error) {
+		if err != nil {
+			return nil, err
+		}
+
+		return api.Get(ccClient, req)
+	}
+
+	stackRequestFactory := apiClient.HandleFiltersAndParameters(
+		apiClient.Authorize(apiClient.NewGetStacksRequest),
+	)
+
+	parseStacks := models.StacksParser{}.Parse
+	if apiClient.V3 {
+		parseStacks = models.V3StacksParser{}.Parse
+	}
+
+	lookups := resource_mapper.Lookups{
+		Stacks: func(name string) (models.Stacks, error) {
+			body, err := get(stackRequestFactory(api.EqualFilter{Name: "name", Value: name}, map[string]interface{}{}))
+			if err != nil {
+				return nil, err
+			}
+
+			return parseStacks(body)
+		},
+	}
+
+	if apiClient.V3 {
+		lookups.Droplet = func(appGuid string) (models.Droplet, error) {
+			body, err := get(apiClient.NewGetCurrentDropletRequest(appGuid))
+
+			// An app that never staged has no droplet.
+			if ccErr, ok := err.(api.CloudControllerError); ok && ccErr.StatusCode == http.StatusNotFound {
+				return models.Droplet{}, nil
+			}
+			if err != nil {
+				return models.Droplet{}, err
+			}
+
+			return models.DropletParser{}.Parse(body)
+		}
+	} else {
+		lookups.App = func(guid string) (models.Application, error) {
+			body, err := get(apiClient.NewGetAppRequest(guid))
+			if err != nil {
+				return models.Application{}, err
+			}
+
+			return models.ApplicationParser{}.Parse(body)
+		}
+	}
+
+	return lookups
+}
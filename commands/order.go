@@ -0,0 +1,113 @@
+package commands
+
+import (
+	"sort"
+
+	"github.com/cloudfoundry-incubator/app-restarter/models"
+)
+
+// orderApps sorts the apps by OrderBy, breaking ties by name and guid so that
+// every run restarts them in the same order. With GroupBySpace the apps are
+// sorted by org and space first, so that the apps of a space are together.
+// Without either the apps are left in the order they were listed in.
+func (exe *RestartAppsExecutor) orderApps(apps models.Applications, spaceMap map[string]models.Space) models.Applications {
+	if exe.OrderBy == "" && !exe.GroupBySpace {
+		return apps
+	}
+
+	ordered := make(models.Applications, len(apps))
+	copy(ordered, apps)
+
+	spaceKey := func(app models.Application) []string {
+		space := spaceMap[app.SpaceGuid]
+		return []string{space.Organization.Name, space.Name, app.SpaceGuid}
+	}
+
+	sort.SliceStable(ordered, func(i, j int) bool {
+		left, right := ordered[i], ordered[j]
+
+		if exe.GroupBySpace {
+			if c := compareStrings(spaceKey(left), spaceKey(right)); c != 0 {
+				return c < 0
+			}
+		}
+
+		c := exe.compareApps(left, right, spaceMap)
+		if exe.Reverse {
+			c = -c
+		}
+		if c != 0 {
+			return c < 0
+		}
+
+		return compareStrings([]string{left.Name, left.Guid}, []string{right.Name, right.Guid}) < 0
+	})
+
+	return ordered
+}
+
+func (exe *RestartAppsExecutor) compareApps(left, right models.Application, spaceMap map[string]models.Space) int {
+	leftSpace, rightSpace := spaceMap[left.SpaceGuid], spaceMap[right.SpaceGuid]
+
+	switch exe.OrderBy {
+	case "name":
+		return compareStrings([]string{left.Name}, []string{right.Name})
+	case "space":
+		return compareStrings(
+			[]string{leftSpace.Name, leftSpace.Organization.Name, left.Name},
+			[]string{rightSpace.Name, rightSpace.Organization.Name, right.Name},
+		)
+	case "org":
+		return compareStrings(
+			[]string{leftSpace.Organization.Name, leftSpace.Name, left.Name},
+			[]string{rightSpace.Organization.Name, rightSpace.Name, right.Name},
+		)
+	case "created":
+		switch {
+		case left.CreatedAt.Before(right.CreatedAt):
+			return -1
+		case right.CreatedAt.Before(left.CreatedAt):
+			return 1
+		}
+	case "memory":
+		switch {
+		case left.Memory < right.Memory:
+			return -1
+		case left.Memory > right.Memory:
+			return 1
+		}
+	}
+
+	return 0
+}
+
+// spaceGroups splits ordered apps into runs of apps in the same space. All
+// apps are a single group unless GroupBySpace is set.
+func (exe *RestartAppsExecutor) spaceGroups(apps models.Applications) []models.Applications {
+	if !exe.GroupBySpace {
+		return []models.Applications{apps}
+	}
+
+	var groups []models.Applications
+	for i, app := range apps {
+		if i == 0 || app.SpaceGuid != apps[i-1].SpaceGuid {
+			groups = append(groups, models.Applications{})
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], app)
+	}
+
+	return groups
+}
+
+func compareStrings(left, right []string) int {
+	for i := range left {
+		switch {
+		case left[i] < right[i]:
+			return -1
+		case left[i] > right[i]:
+			return 1
+		}
+	}
+
+	return 0
+}
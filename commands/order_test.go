@@ -0,0 +1,116 @@
+package commands
+
+import (
+	"time"
+
+	"github.com/cloudfoundry-incubator/app-restarter/models"
+
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("Order", func() {
+	spaceMap := map[string]models.Space{}
+	for _, s := range []struct{ guid, name, org string }{
+		{"space-1", "dev", "org-b"},
+		{"space-2", "prod", "org-a"},
+		{"space-3", "dev", "org-a"},
+	} {
+		space := models.Space{}
+		space.Guid = s.guid
+		space.Name = s.name
+		space.Organization.Name = s.org
+		spaceMap[s.guid] = space
+	}
+
+	created := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	app := func(name string, spaceGuid string, memory int, age time.Duration) models.Application {
+		application := models.Application{}
+		application.Name = name
+		application.Guid = name + "-guid"
+		application.SpaceGuid = spaceGuid
+		application.Memory = memory
+		application.CreatedAt = created.Add(-age)
+		return application
+	}
+
+	apps := models.Applications{
+		app("c", "space-1", 512, time.Hour),
+		app("a", "space-2", 1024, 3*time.Hour),
+		app("d", "space-3", 256, 2*time.Hour),
+		app("b", "space-1", 1024, 4*time.Hour),
+	}
+
+	names := func(apps models.Applications) []string {
+		var result []string
+		for _, application := range apps {
+			result = append(result, application.Name)
+		}
+		return result
+	}
+
+	table.DescribeTable("orderApps",
+		func(exe RestartAppsExecutor, expected []string) {
+			Expect(names(exe.orderApps(apps, spaceMap))).To(Equal(expected))
+		},
+		table.Entry("keeps the listed order without an order",
+			RestartAppsExecutor{}, []string{"c", "a", "d", "b"}),
+		table.Entry("by name",
+			RestartAppsExecutor{OrderBy: "name"}, []string{"a", "b", "c", "d"}),
+		table.Entry("by space, then org",
+			RestartAppsExecutor{OrderBy: "space"}, []string{"d", "b", "c", "a"}),
+		table.Entry("by org, then space",
+			RestartAppsExecutor{OrderBy: "org"}, []string{"d", "a", "b", "c"}),
+		table.Entry("by creation time",
+			RestartAppsExecutor{OrderBy: "created"}, []string{"b", "a", "d", "c"}),
+		table.Entry("by memory, breaking ties by name",
+			RestartAppsExecutor{OrderBy: "memory"}, []string{"d", "c", "a", "b"}),
+		table.Entry("by memory reversed, still breaking ties by name",
+			RestartAppsExecutor{OrderBy: "memory", Reverse: true}, []string{"a", "b", "c", "d"}),
+		table.Entry("grouped by space without an order",
+			RestartAppsExecutor{GroupBySpace: true}, []string{"d", "a", "b", "c"}),
+		table.Entry("grouped by space and by memory within a space",
+			RestartAppsExecutor{OrderBy: "memory", Reverse: true, GroupBySpace: true}, []string{"d", "a", "b", "c"}),
+	)
+
+	ginkgo.It("does not change the listed apps", func() {
+		exe := RestartAppsExecutor{OrderBy: "name"}
+		exe.orderApps(apps, spaceMap)
+		Expect(names(apps)).To(Equal([]string{"c", "a", "d", "b"}))
+	})
+
+	table.DescribeTable("compareApps",
+		func(orderBy string, left, right models.Application, expected int) {
+			exe := RestartAppsExecutor{OrderBy: orderBy}
+			Expect(exe.compareApps(left, right, spaceMap)).To(Equal(expected))
+			Expect(exe.compareApps(right, left, spaceMap)).To(Equal(-expected))
+		},
+		table.Entry("by name", "name", apps[1], apps[0], -1),
+		table.Entry("by space", "space", apps[2], apps[1], -1),
+		table.Entry("by org", "org", apps[2], apps[0], -1),
+		table.Entry("by creation time", "created", apps[3], apps[0], -1),
+		table.Entry("by memory", "memory", apps[2], apps[0], -1),
+		table.Entry("with equal memory", "memory", apps[1], apps[3], 0),
+		table.Entry("without an order", "", apps[1], apps[0], 0),
+	)
+
+	ginkgo.Describe("spaceGroups", func() {
+		ginkgo.It("puts all apps in a single group unless grouping by space", func() {
+			exe := RestartAppsExecutor{}
+			Expect(exe.spaceGroups(apps)).To(Equal([]models.Applications{apps}))
+		})
+
+		ginkgo.It("splits ordered apps into runs of apps in the same space", func() {
+			exe := RestartAppsExecutor{GroupBySpace: true}
+			groups := exe.spaceGroups(exe.orderApps(apps, spaceMap))
+
+			var groupNames [][]string
+			for _, group := range groups {
+				groupNames = append(groupNames, names(group))
+			}
+			Expect(groupNames).To(Equal([][]string{{"d"}, {"a"}, {"b", "c"}}))
+		})
+	})
+})
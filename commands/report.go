@@ -0,0 +1,34 @@
+package commands
+
+import "github.com/cloudfoundry-incubator/app-restarter/ui"
+
+func newReport(results []appResult, summary ui.RestartSummary) ui.Report {
+	report := ui.Report{
+		Apps: []ui.AppRecord{},
+		Summary: ui.ReportSummary{
+			RestartSummary: summary,
+		},
+	}
+
+	for _, result := range results {
+		record := ui.AppRecord{
+			Guid:          result.App.App.Guid,
+			Name:          result.App.Name(),
+			Organization:  result.App.Organization(),
+			Space:         result.App.Space(),
+			PreviousState: result.App.App.State,
+			Outcome:       outcomeName(result.Outcome),
+			Retries:       result.Retries,
+			StartedAt:     result.StartedAt.UTC(),
+			FinishedAt:    result.FinishedAt.UTC(),
+		}
+
+		if result.Err != nil {
+			record.Error = result.Err.Error()
+		}
+
+		report.Apps = append(report.Apps, record)
+	}
+
+	return report
+}
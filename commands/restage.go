@@ -0,0 +1,117 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/cloudfoundry-incubator/app-restarter/commands/displayhelpers"
+	"github.com/cloudfoundry-incubator/app-restarter/models"
+)
+
+const restageStrategy = "restage"
+
+const defaultStagingTimeout = 15 * time.Minute
+
+// restage stages an app again, so that it picks up new buildpacks and
+// stacks, and waits for it to be running on the new droplet. An app that
+// fails to stage keeps running its old droplet on v3.
+func (exe *RestartAppsExecutor) restage(
+	result *appResult,
+	appRestarter AppRestarter,
+	timeout time.Duration,
+) (int, error) {
+	appPrinter := result.App
+
+	var packageGuid string
+	err := exe.withRetries(result, func() error {
+		var err error
+		packageGuid, err = appRestarter.Package(appPrinter.App.Guid)
+		return err
+	})
+	if err != nil {
+		return Err, err
+	}
+
+	// Staging is not retried, as a retry after an error that reached the
+	// Cloud Controller would stage the package twice.
+	buildGuid, err := appRestarter.Restage(appPrinter.App.Guid, packageGuid)
+	if err != nil {
+		return Err, err
+	}
+
+	stagingTimeout := stagingTimeout()
+	staging, err := exe.waitForStaging(appPrinter, appRestarter, buildGuid, stagingTimeout)
+	if err != nil {
+		return Err, err
+	}
+
+	switch staging.State {
+	case models.StagingFailed:
+		if staging.Reason == "" {
+			return StagingFailed, errors.New("Staging failed")
+		}
+		return StagingFailed, fmt.Errorf("Staging failed: %s", staging.Reason)
+	case models.StagingStaged:
+	default:
+		return StagingFailed, fmt.Errorf("Staging did not finish within %s", stagingTimeout)
+	}
+
+	err = exe.withRetries(result, func() error {
+		return appRestarter.UseDroplet(appPrinter.App.Guid, staging.DropletGuid)
+	})
+	if err != nil {
+		return Err, err
+	}
+
+	return exe.waitForRunning(result, appRestarter, timeout)
+}
+
+// waitForStaging polls the staging state of the app until staging is done
+// or the timeout elapsed, in which case the state is still pending. Polling
+// goes on after transient errors only.
+func (exe *RestartAppsExecutor) waitForStaging(
+	appPrinter *displayhelpers.AppPrinter,
+	appRestarter AppRestarter,
+	buildGuid string,
+	timeout time.Duration,
+) (models.Staging, error) {
+	deadline := time.After(timeout)
+
+	poll := time.NewTicker(instancePollInterval)
+	defer poll.Stop()
+
+	for {
+		select {
+		case <-deadline:
+			return models.Staging{State: models.StagingPending}, nil
+		case <-poll.C:
+			exe.RestartAppsUI.StagingEach(appPrinter)
+
+			staging, err := appRestarter.Staging(appPrinter.App.Guid, buildGuid)
+			if err != nil {
+				if exe.retryable(err) {
+					continue
+				}
+				return models.Staging{}, err
+			}
+
+			if staging.State == models.StagingStaged || staging.State == models.StagingFailed {
+				return staging, nil
+			}
+		}
+	}
+}
+
+// stagingTimeout is read in seconds from CF_STAGING_TIMEOUT, like the
+// startup timeout from CF_STARTUP_TIMEOUT.
+func stagingTimeout() time.Duration {
+	t, err := strconv.Atoi(os.Getenv("CF_STAGING_TIMEOUT"))
+	if err != nil || t <= 0 {
+		return defaultStagingTimeout
+	}
+
+	return time.Duration(t) * time.Second
+}
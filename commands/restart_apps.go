@@ -1,38 +1,222 @@
 package commands
 
 import (
+	"os"
+	"time"
+
+	"github.com/cloudfoundry-incubator/app-restarter/api"
 	"github.com/cloudfoundry-incubator/app-restarter/commands/errorhelpers"
 	"github.com/cloudfoundry-incubator/app-restarter/resource_mapper"
 	"github.com/cloudfoundry-incubator/app-restarter/ui"
 )
 
 type RestartAppsCommand struct {
-	Organization string `short:"o" value-name:"ORG" description:"Organization to restrict the app restarts"`
-	Space        string `short:"s" value-name:"SPACE" description:"Space in the targeted organization to restrict the app restarts"`
+	Organizations []string `short:"o" value-name:"ORG" description:"Organization to restrict the app restarts, can be repeated"`
+	Spaces        []string `short:"s" value-name:"SPACE" description:"Space in the targeted organization or ORG/SPACE to restrict the app restarts, can be repeated"`
+	Parallel      int      `long:"parallel" value-name:"N" default:"1" description:"Number of apps to restart concurrently"`
+	DryRun        bool     `long:"dry-run" description:"Print the apps that would be restarted without restarting them"`
+	Force         bool     `short:"f" long:"force" description:"Restart the apps without asking for confirmation, also after the canary apps"`
+	APIVersion    string   `long:"api-version" value-name:"VERSION" choice:"auto" choice:"v2" choice:"v3" default:"auto" description:"Cloud Controller API version to use, auto-detected by default"`
+	Rolling       bool     `long:"rolling" description:"Restart the instances of each app one at a time instead of stopping and starting the whole app"`
+	Strategy      string   `long:"strategy" value-name:"STRATEGY" choice:"restart" choice:"restage" default:"restart" description:"Restart the apps, or restage them to pick up new buildpacks and stacks"`
+	StateFile     string   `long:"state-file" value-name:"PATH" description:"File to record the outcome of each app in as it completes"`
+	Resume        bool     `long:"resume" description:"Skip apps recorded as restarted in the state file by a previous run"`
+	Output        string   `long:"output" value-name:"FORMAT" choice:"text" choice:"json" default:"text" description:"Output format of the run, json prints a report of every app to stdout"`
+	ReportFile    string   `long:"report-file" value-name:"PATH" description:"File to write a JSON report of every app to"`
+
+	AppPatterns     []string `long:"app-pattern" value-name:"REGEX" description:"Only restart apps with a name matching the pattern, can be repeated"`
+	ExcludePatterns []string `long:"exclude-pattern" value-name:"REGEX" description:"Do not restart apps with a name matching the pattern, can be repeated"`
+	ExcludeOrgs     []string `long:"exclude-org" value-name:"ORG" description:"Do not restart apps in the organization, can be repeated"`
+	ExcludeSpaces   []string `long:"exclude-space" value-name:"SPACE" description:"Do not restart apps in the space of the targeted organization or ORG/SPACE, can be repeated"`
+	Buildpacks      []string `long:"buildpack" value-name:"NAME" description:"Only restart apps pushed with or detected to use the buildpack, can be repeated"`
+	Stacks          []string `long:"stack" value-name:"NAME" description:"Only restart apps running on the stack, can be repeated"`
+	AppsFile        string   `long:"apps-file" value-name:"PATH" description:"File listing the apps to restart by GUID or ORG/SPACE/APP, one per line, or - for stdin"`
+
+	Canary     int           `long:"canary" value-name:"N" description:"Restart N apps first and only go on with the remaining apps once all of them are healthy"`
+	CanaryWait time.Duration `long:"canary-wait" value-name:"DURATION" description:"Time to wait after the canary apps before going on, instead of asking for confirmation"`
+
+	Window            string `long:"window" value-name:"START-END" description:"Only restart apps between the HH:MM times, e.g. 02:00-05:00"`
+	Timezone          string `long:"timezone" value-name:"TZ" description:"Timezone of the window, e.g. Europe/Berlin, the local timezone by default"`
+	ExitOutsideWindow bool   `long:"exit-outside-window" description:"Exit when the window closes instead of waiting for it to open again"`
+
+	Rate      string `long:"rate" value-name:"N/min" description:"Start restarting no more than N apps per minute, or per second or hour with N/s or N/h"`
+	MaxPerOrg int    `long:"max-per-org" value-name:"N" description:"Restart no more than N apps of an org at the same time"`
+
+	MaxFailures       int     `long:"max-failures" value-name:"N" description:"Stop restarting further apps once N apps failed"`
+	MaxFailurePercent float64 `long:"max-failure-percent" value-name:"P" description:"Stop restarting further apps once P percent of all apps failed"`
+	FailOnWarning     bool    `long:"fail-on-warning" description:"Exit with an error when apps could not be restarted for lack of authorization, too"`
+
+	OrderBy      string `long:"order-by" value-name:"KEY" choice:"name" choice:"space" choice:"org" choice:"created" choice:"memory" description:"Restart the apps ordered by name, space, org, creation time or memory, in the order they are listed by default"`
+	Reverse      bool   `long:"reverse" description:"Restart the apps in reverse order, requires --order-by"`
+	GroupBySpace bool   `long:"group-by-space" description:"Finish restarting the apps of a space before going on with the next space"`
+
+	RetryAttempts   int           `long:"retry-attempts" value-name:"N" default:"3" description:"Number of times to try a Cloud Controller request that fails with a transient error"`
+	RetryBackoff    time.Duration `long:"retry-backoff" value-name:"DURATION" default:"2s" description:"Time to wait before the first retry, doubled for every further retry"`
+	RetryMaxBackoff time.Duration `long:"retry-max-backoff" value-name:"DURATION" default:"30s" description:"Maximum time to wait between retries"`
+	RetryJitter     float64       `long:"retry-jitter" value-name:"FRACTION" default:"0.2" description:"Fraction by which to randomly vary the time between retries"`
+	RetryStatuses   []int         `long:"retry-status" value-name:"CODE" default:"429" default:"502" default:"503" default:"504" description:"HTTP status code to retry, can be repeated"`
+	RetryErrorCodes []string      `long:"retry-error-code" value-name:"CODE" default:"CF-AsyncServiceInProgress" default:"CF-ServiceUnavailable" default:"CF-RunnerUnavailable" default:"CF-StagerUnavailable" default:"CF-InstancesUnavailable" description:"Cloud Controller error code to retry, can be repeated"`
 }
 
 func (command RestartAppsCommand) Execute(flags []string) error {
 	cliConnection := Context.CLIConnection
 
-	err := errorhelpers.ErrorIfOrgAndSpacesSet(command.Organization, command.Space)
+	err := errorhelpers.ErrorIfResumeWithoutStateFile(command.Resume, command.StateFile)
+	if err != nil {
+		return err
+	}
+
+	err = errorhelpers.ErrorIfAppsFileAndOrgOrSpaceSet(command.AppsFile, command.Organizations, command.Spaces)
+	if err != nil {
+		return err
+	}
+
+	err = errorhelpers.ErrorIfCanaryWaitWithoutCanary(command.Canary, command.CanaryWait)
+	if err != nil {
+		return err
+	}
+
+	err = errorhelpers.ErrorIfRollingRestage(command.Rolling, command.Strategy)
+	if err != nil {
+		return err
+	}
+
+	err = errorhelpers.ErrorIfReverseWithoutOrderBy(command.Reverse, command.OrderBy)
 	if err != nil {
 		return err
 	}
 
-	appsGetter, err := resource_mapper.NewAppsGetterFunc(cliConnection, command.Organization, command.Space)
+	err = errorhelpers.ErrorIfWindowOptionsWithoutWindow(command.Window, command.Timezone, command.ExitOutsideWindow)
 	if err != nil {
 		return err
 	}
 
-	restartAppsUI, err := ui.NewRestartApps(cliConnection, command.Organization, command.Space)
+	err = errorhelpers.ErrorIfWindowExitWithoutStateFile(command.ExitOutsideWindow, command.StateFile)
 	if err != nil {
 		return err
 	}
 
+	if !command.DryRun {
+		err = errorhelpers.ErrorIfConfirmWithAppsFromStdin(command.AppsFile, command.Force)
+		if err != nil {
+			return err
+		}
+	}
+
+	appsFilter, err := resource_mapper.NewAppsFilter(
+		cliConnection,
+		command.AppPatterns,
+		command.ExcludePatterns,
+		command.ExcludeOrgs,
+		command.ExcludeSpaces,
+		command.Buildpacks,
+		command.Stacks,
+	)
+	if err != nil {
+		return err
+	}
+
+	var appsGetter resource_mapper.AppsGetterFunc
+	if command.AppsFile != "" {
+		lines, err := readAppsFile(command.AppsFile)
+		if err != nil {
+			return err
+		}
+
+		appsGetter, err = resource_mapper.NewAppsListGetterFunc(cliConnection, lines, appsFilter)
+		if err != nil {
+			return err
+		}
+	} else {
+		appsGetter, err = resource_mapper.NewAppsGetterFunc(cliConnection, command.Organizations, command.Spaces, appsFilter)
+		if err != nil {
+			return err
+		}
+	}
+
+	restartAppsUI, err := ui.NewRestartApps(cliConnection, command.Organizations, command.Spaces)
+	if err != nil {
+		return err
+	}
+
+	restartAppsUI.Force = command.Force
+
+	if command.Output == "json" {
+		restartAppsUI.Out = os.Stderr
+	}
+
 	cmd := RestartAppsExecutor{
 		AppsGetterFunc: appsGetter,
 		RestartAppsUI:  &restartAppsUI,
+		RestartPlanUI:  &ui.RestartPlan{Username: restartAppsUI.Username, Restage: command.Strategy == restageStrategy, Out: restartAppsUI.Out},
+		Parallel:       command.Parallel,
+		DryRun:         command.DryRun,
+		APIVersion:     command.APIVersion,
+		Rolling:        command.Rolling,
+		Strategy:       command.Strategy,
+		Canary:         command.Canary,
+		CanaryWait:     command.CanaryWait,
+
+		MaxFailures:       command.MaxFailures,
+		MaxFailurePercent: command.MaxFailurePercent,
+		FailOnWarning:     command.FailOnWarning,
+
+		OrderBy:      command.OrderBy,
+		Reverse:      command.Reverse,
+		GroupBySpace: command.GroupBySpace,
+
+		MaxPerOrg: command.MaxPerOrg,
+
+		Retry: api.RetryPolicy{
+			MaxAttempts:          command.RetryAttempts,
+			Backoff:              command.RetryBackoff,
+			MaxBackoff:           command.RetryMaxBackoff,
+			Jitter:               command.RetryJitter,
+			RetryableStatusCodes: command.RetryStatuses,
+			RetryableErrorCodes:  command.RetryErrorCodes,
+		},
+	}
+
+	if command.Output == "json" || command.ReportFile != "" {
+		cmd.RestartReportUI = &ui.RestartReport{
+			JSON: command.Output == "json",
+			File: command.ReportFile,
+		}
 	}
 
-	return cmd.Execute(cliConnection)
+	if command.Window != "" {
+		cmd.Window, err = NewMaintenanceWindow(command.Window, command.Timezone)
+		if err != nil {
+			return err
+		}
+		cmd.ExitOutsideWindow = command.ExitOutsideWindow
+	}
+
+	if command.Rate != "" {
+		cmd.RateInterval, err = ParseRate(command.Rate)
+		if err != nil {
+			return err
+		}
+	}
+
+	if command.StateFile != "" && !command.DryRun {
+		cmd.Checkpoint, err = NewCheckpoint(command.StateFile, command.Resume)
+		if err != nil {
+			return err
+		}
+	}
+
+	// A dry run shows the apps a resumed run would skip, but must not write
+	// the state file.
+	if command.StateFile != "" && command.DryRun && command.Resume {
+		cmd.Checkpoint, err = ReadCheckpoint(command.StateFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	ctx, stop := interruptContext(&restartAppsUI)
+	defer stop()
+
+	return cmd.Execute(ctx, cliConnection)
 }
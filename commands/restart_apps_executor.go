@@ -1,17 +1,23 @@
 package commands
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/cloudfoundry-incubator/app-restarter/api"
 	"github.com/cloudfoundry-incubator/app-restarter/commands/displayhelpers"
+	"github.com/cloudfoundry-incubator/app-restarter/commands/errorhelpers"
 	"github.com/cloudfoundry-incubator/app-restarter/models"
 	"github.com/cloudfoundry-incubator/app-restarter/resource_mapper"
 	"github.com/cloudfoundry-incubator/app-restarter/ui"
 	"sync"
+	"sync/atomic"
 )
 
 const (
@@ -19,33 +25,102 @@ const (
 	Stopped
 	Warning
 	Err
+	Crashed
+	TimedOut
+	Skipped
+	NotAttempted
+	StagingFailed
 )
 
+var outcomeNames = map[int]string{
+	Success:       "Success",
+	Stopped:       "Stopped",
+	Warning:       "Warning",
+	Err:           "Err",
+	Crashed:       "Crashed",
+	TimedOut:      "TimedOut",
+	Skipped:       "Skipped",
+	NotAttempted:  "NotAttempted",
+	StagingFailed: "StagingFailed",
+}
+
+func outcomeName(outcome int) string {
+	return outcomeNames[outcome]
+}
+
+const instancePollInterval = 5 * time.Second
+
 type RestartAppsExecutor struct {
 	AppsGetterFunc resource_mapper.AppsGetterFunc
 	RestartAppsUI  *ui.RestartApps
+	RestartPlanUI  *ui.RestartPlan
+	Parallel       int
+	DryRun         bool
+	APIVersion     string
+	Rolling        bool
+	Strategy       string
+	Canary         int
+	CanaryWait     time.Duration
+	Checkpoint     *Checkpoint
+	Retry          api.RetryPolicy
+
+	MaxFailures       int
+	MaxFailurePercent float64
+	FailOnWarning     bool
+
+	OrderBy      string
+	Reverse      bool
+	GroupBySpace bool
+
+	Window            *MaintenanceWindow
+	ExitOutsideWindow bool
+
+	RateInterval time.Duration
+	MaxPerOrg    int
+
+	RestartReportUI *ui.RestartReport
 }
 
-func (exe *RestartAppsExecutor) Execute(cliConnection api.Connection) error {
-	exe.RestartAppsUI.BeforeAll() //move me to the command
-
+func (exe *RestartAppsExecutor) Execute(ctx context.Context, cliConnection api.Connection) error {
 	apiClient, err := api.NewClient(cliConnection)
 	if err != nil {
 		return err
 	}
 
-	appRequestFactory := apiClient.HandleFiltersAndParameters(
-		apiClient.Authorize(apiClient.NewGetAppsRequest),
-	)
+	httpClient, err := api.NewHttpClient(cliConnection)
+	if err != nil {
+		return err
+	}
 
-	appPaginatedRequester, err := api.NewPaginatedRequester(cliConnection, appRequestFactory)
+	// Requests are authorized with a fresh token again after a 401 before
+	// the response is considered for a retry.
+	ccClient := &api.RetryingClient{
+		Client: &api.ReauthorizingClient{Client: httpClient, Tokens: apiClient.Tokens},
+		Policy: exe.Retry,
+	}
+
+	err = apiClient.UseAPIVersion(exe.APIVersion, ccClient)
 	if err != nil {
 		return err
 	}
 
+	var appsParser resource_mapper.ApplicationsParser = models.ApplicationsParser{}
+	var spacesParser resource_mapper.SpacesParser = models.SpacesParser{}
+	if apiClient.V3 {
+		appsParser = models.V3ApplicationsParser{}
+		spacesParser = models.V3SpacesParser{}
+	}
+
+	appRequestFactory := apiClient.HandleFiltersAndParameters(
+		apiClient.Authorize(apiClient.NewGetAppsRequest),
+	)
+
+	appPaginatedRequester := api.NewPaginatedRequester(ccClient, appRequestFactory, apiClient.NewLinkRequest)
+
 	apps, err := exe.AppsGetterFunc(
-		models.ApplicationsParser{},
+		appsParser,
 		appPaginatedRequester,
+		newLookups(apiClient, ccClient),
 	)
 	if err != nil {
 		return err
@@ -55,13 +130,10 @@ func (exe *RestartAppsExecutor) Execute(cliConnection api.Connection) error {
 		apiClient.Authorize(apiClient.NewGetSpacesRequest),
 	)
 
-	spacePaginatedRequester, err := api.NewPaginatedRequester(cliConnection, spaceRequestFactory)
-	if err != nil {
-		return err
-	}
+	spacePaginatedRequester := api.NewPaginatedRequester(ccClient, spaceRequestFactory, apiClient.NewLinkRequest)
 
 	spaces, err := resource_mapper.Spaces(
-		models.SpacesParser{},
+		spacesParser,
 		spacePaginatedRequester,
 	)
 	if err != nil {
@@ -73,20 +145,112 @@ func (exe *RestartAppsExecutor) Execute(cliConnection api.Connection) error {
 		spaceMap[space.Guid] = space
 	}
 
-	stopped, warnings, errors := exe.restartApps(cliConnection, apps, spaceMap)
-	exe.RestartAppsUI.AfterAll(len(apps), stopped, warnings, errors)
+	if apiClient.V3 && exe.OrderBy == "memory" {
+		processRequestFactory := apiClient.HandleFiltersAndParameters(
+			apiClient.Authorize(apiClient.NewGetProcessesRequest),
+		)
 
-	return nil
+		processPaginatedRequester := api.NewPaginatedRequester(ccClient, processRequestFactory, apiClient.NewLinkRequest)
+
+		memory, err := resource_mapper.WebProcessMemory(
+			models.V3ProcessesParser{},
+			processPaginatedRequester,
+			apps,
+		)
+		if err != nil {
+			return err
+		}
+
+		for i := range apps {
+			apps[i].Memory = memory[apps[i].Guid]
+		}
+	}
+
+	apps = exe.orderApps(apps, spaceMap)
+
+	if exe.DryRun {
+		exe.RestartPlanUI.Show(exe.restartPlan(apps, spaceMap))
+		return nil
+	}
+
+	if !exe.RestartAppsUI.BeforeAll(ctx, exe.restartScope(apps, spaceMap)) {
+		if ctx.Err() != nil {
+			return errorhelpers.InterruptedError
+		}
+		return errorhelpers.NotConfirmedError
+	}
+
+	restarter := NewAppRestarter(cliConnection, apiClient.V3, apiClient.Tokens)
+
+	var results []appResult
+	var summary ui.RestartSummary
+	if exe.Canary > 0 {
+		results, summary, err = exe.restartWithCanaries(ctx, restarter, apps, spaceMap)
+	} else {
+		results, summary, err = exe.restartApps(ctx, restarter, apps, spaceMap)
+	}
+
+	if err == nil && ctx.Err() != nil {
+		err = errorhelpers.InterruptedError
+	}
+	if err == nil {
+		err = exe.restartFailedError(summary)
+	}
+
+	exe.RestartAppsUI.AfterAll(summary, exe.failedApps(results))
+	exe.RestartAppsUI.NotAttempted(notAttemptedApps(results))
+
+	if exe.RestartReportUI != nil {
+		reportErr := exe.RestartReportUI.Write(newReport(results, summary))
+		if reportErr != nil {
+			return reportErr
+		}
+	}
+
+	return err
+}
+
+type appResult struct {
+	App        *displayhelpers.AppPrinter
+	Outcome    int
+	Err        error
+	Retries    int
+	StartedAt  time.Time
+	FinishedAt time.Time
 }
 
-type restartAppFunc func(appPrinter *displayhelpers.AppPrinter, appRestarter AppRestarter) int
+type restartAppFunc func(result *appResult, appRestarter AppRestarter)
 
 func (exe *RestartAppsExecutor) RestartApp(
-	appPrinter *displayhelpers.AppPrinter,
+	result *appResult,
 	appRestarter AppRestarter,
-) int {
+) {
+	if exe.Checkpoint == nil {
+		result.Outcome, result.Err = exe.restartApp(result, appRestarter)
+		return
+	}
+
+	if exe.Checkpoint.Restarted(result.App.App.Guid) {
+		exe.RestartAppsUI.SkippedEach(result.App)
+		result.Outcome = Skipped
+		return
+	}
+
+	result.Outcome, result.Err = exe.restartApp(result, appRestarter)
+
+	err := exe.Checkpoint.Record(result.App.App.Guid, result.Outcome)
+	if err != nil {
+		exe.RestartAppsUI.FailCheckpoint(result.App, err)
+	}
+}
+
+func (exe *RestartAppsExecutor) restartApp(
+	result *appResult,
+	appRestarter AppRestarter,
+) (int, error) {
+	appPrinter := result.App
 	if appPrinter.App.State == models.Stopped {
-		return Stopped
+		return Stopped, nil
 	}
 
 	exe.RestartAppsUI.BeforeEach(appPrinter)
@@ -101,49 +265,318 @@ func (exe *RestartAppsExecutor) RestartApp(
 		}
 	}
 
-	_, err := appRestarter.Restart(appPrinter.App.Guid)
-	if err != nil {
+	var outcome int
+	var err error
+	if exe.Rolling {
+		outcome, err = exe.rollingRestart(result, appRestarter, waitTime)
+	} else if exe.Strategy == restageStrategy {
+		outcome, err = exe.restage(result, appRestarter, waitTime)
+	} else {
+		err = exe.withRetries(result, func() error {
+			_, err := appRestarter.Restart(appPrinter.App.Guid)
+			return err
+		})
+		if _, ok := err.(appLeftStoppedError); ok {
+			exe.RestartAppsUI.StartingAgain(appPrinter)
+			err = exe.withRetries(result, func() error {
+				return appRestarter.Start(appPrinter.App.Guid)
+			})
+		}
+		if err == nil {
+			outcome, err = exe.waitForRunning(result, appRestarter, waitTime)
+		}
+	}
+
+	if err != nil && outcome != StagingFailed {
 		if strings.Contains(err.Error(), "NotAuthorized") {
 			exe.RestartAppsUI.UserWarning(appPrinter)
-			return Warning
+			return Warning, err
 		} else {
 			exe.RestartAppsUI.FailRestart(appPrinter, err)
-			return Err
+			return Err, err
 		}
 	}
 
-	printDot := time.NewTicker(5 * time.Second)
-	go func() {
-		for range printDot.C {
+	switch outcome {
+	case Success:
+		exe.RestartAppsUI.CompletedEach(appPrinter)
+	case Crashed:
+		exe.RestartAppsUI.CrashedEach(appPrinter)
+		err = errors.New("App crashed after restarting")
+	case TimedOut:
+		exe.RestartAppsUI.TimedOutEach(appPrinter, waitTime)
+		err = fmt.Errorf("App did not start within %s", waitTime)
+	case StagingFailed:
+		exe.RestartAppsUI.StagingFailedEach(appPrinter, err)
+	}
+
+	return outcome, err
+}
+
+// instancesCheck decides from the polled instances whether waiting is done
+// and with which outcome.
+type instancesCheck func(models.Instances) (outcome int, done bool)
+
+func allInstancesRunning(desired int) instancesCheck {
+	return func(instances models.Instances) (int, bool) {
+		if instances.Crashed() {
+			return Crashed, true
+		}
+
+		if instances.Running(desired) {
+			return Success, true
+		}
+
+		return 0, false
+	}
+}
+
+// waitForRunning waits for as many instances of the app to be running as it
+// is scaled to. An app scaled to zero instances has none to wait for.
+func (exe *RestartAppsExecutor) waitForRunning(
+	result *appResult,
+	appRestarter AppRestarter,
+	timeout time.Duration,
+) (int, error) {
+	var desired int
+	err := exe.withRetries(result, func() error {
+		var err error
+		desired, err = appRestarter.DesiredInstances(result.App.App.Guid)
+		return err
+	})
+	if err != nil {
+		return Err, err
+	}
+
+	if desired == 0 {
+		return Success, nil
+	}
+
+	return exe.waitForInstances(result.App, appRestarter, timeout, allInstancesRunning(desired)), nil
+}
+
+// waitForInstances polls the instances of the app until the check is done or
+// the timeout elapsed. Errors while polling are expected until the app has
+// been placed and are not fatal.
+func (exe *RestartAppsExecutor) waitForInstances(
+	appPrinter *displayhelpers.AppPrinter,
+	appRestarter AppRestarter,
+	timeout time.Duration,
+	check instancesCheck,
+) int {
+	deadline := time.After(timeout)
+
+	poll := time.NewTicker(instancePollInterval)
+	defer poll.Stop()
+
+	for {
+		select {
+		case <-deadline:
+			return TimedOut
+		case <-poll.C:
 			exe.RestartAppsUI.DuringEach(appPrinter)
+
+			instances, err := appRestarter.Instances(appPrinter.App.Guid)
+			if err != nil {
+				continue
+			}
+
+			if outcome, done := check(instances); done {
+				return outcome
+			}
+		}
+	}
+}
+
+// restartApps restarts the apps until they are all done, the failure
+// threshold is reached or the context is cancelled. Restarts in flight are
+// still finished in the latter cases and the apps that were never dispatched
+// are returned as NotAttempted.
+func (exe *RestartAppsExecutor) restartApps(
+	ctx context.Context,
+	restarter AppRestarter,
+	apps models.Applications,
+	spaceMap map[string]models.Space,
+) ([]appResult, ui.RestartSummary, error) {
+	ctx, stopDispatching := context.WithCancel(ctx)
+	defer stopDispatching()
+
+	// The window is checked by the generator or the throttle and by the
+	// workers, so windowClosed is guarded by windowLock.
+	var windowLock sync.Mutex
+	windowClosed := false
+	dispatch := func(remaining int) bool {
+		windowLock.Lock()
+		defer windowLock.Unlock()
+
+		if exe.waitForWindow(ctx, remaining) {
+			return true
 		}
+
+		windowClosed = windowClosed || ctx.Err() == nil
+		return false
+	}
+
+	// An app handed out waits for a free worker, which can take as long as a
+	// restart, so the window is checked again right before the app starts.
+	var started int32
+	start := func() bool {
+		remaining := len(apps) - int(atomic.AddInt32(&started, 1)) + 1
+		if dispatch(remaining) {
+			return true
+		}
+
+		stopDispatching()
+		return false
+	}
+
+	// The throttle holds apps back after the generator handed them out, so
+	// it is the one to check them right before they start.
+	limits := newThrottle(exe.RateInterval, exe.MaxPerOrg, spaceMap, len(apps))
+	generatorDispatch := dispatch
+	if limits != nil {
+		limits.dispatch = dispatch
+		limits.restarts = exe.restartable
+		generatorDispatch = func(int) bool { return true }
+	}
+
+	finished := make(chan struct{}, len(apps))
+	runningAppsChan := generateAppsChan(ctx, exe.spaceGroups(apps), finished, generatorDispatch)
+	outputsChan, waitDone := processAppsChan(ctx, restarter, spaceMap, exe.RestartApp, start, runningAppsChan, len(apps), exe.Parallel, limits)
+
+	go func() {
+		waitDone.Wait()
+		close(outputsChan)
 	}()
 
-	time.Sleep(waitTime)
+	var err error
+	results, summary := outputAppsChan(outputsChan, func(summary ui.RestartSummary) {
+		finished <- struct{}{}
 
-	printDot.Stop()
+		if err == nil && exe.failureThresholdReached(summary, len(apps)) {
+			err = errorhelpers.FailureThresholdError
+			stopDispatching()
+		}
+	})
+	summary.Attempts = len(results)
+
+	windowLock.Lock()
+	if err == nil && windowClosed {
+		err = errorhelpers.WindowClosedError
+	}
+	windowLock.Unlock()
 
-	exe.RestartAppsUI.CompletedEach(appPrinter)
+	results, summary = appendNotAttempted(results, summary, apps, spaceMap)
 
-	return Success
+	return results, summary, err
 }
 
-func (exe *RestartAppsExecutor) restartApps(cliConnection api.Connection, apps models.Applications, spaceMap map[string]models.Space) (int, int, int) {
-	runningAppsChan := generateAppsChan(apps)
-	outputsChan, waitDone := processAppsChan(cliConnection, spaceMap, exe.RestartApp, runningAppsChan, len(apps))
+func (exe *RestartAppsExecutor) restartPlan(apps models.Applications, spaceMap map[string]models.Space) []ui.PlanEntry {
+	var entries []ui.PlanEntry
+
+	canaries, _ := exe.splitCanaries(apps)
+	isCanary := map[string]bool{}
+	for _, app := range canaries {
+		isCanary[app.Guid] = true
+	}
+
+	for _, app := range apps {
+		entries = append(entries, ui.PlanEntry{
+			App: &displayhelpers.AppPrinter{
+				App:    app,
+				Spaces: spaceMap,
+			},
+			State:     app.State,
+			Skipped:   app.State == models.Stopped,
+			Restarted: exe.Checkpoint != nil && exe.Checkpoint.Restarted(app.Guid),
+			Canary:    isCanary[app.Guid],
+		})
+	}
+
+	return entries
+}
+
+// restartScope counts the apps that are about to be restarted by org, leaving
+// out the apps that are skipped.
+func (exe *RestartAppsExecutor) restartScope(apps models.Applications, spaceMap map[string]models.Space) ui.RestartScope {
+	var scope ui.RestartScope
+	orgIndex := map[string]int{}
+	seenSpaces := map[string]bool{}
+
+	for _, app := range apps {
+		if !exe.restartable(app) {
+			continue
+		}
+
+		orgName := spaceMap[app.SpaceGuid].Organization.Name
+		i, ok := orgIndex[orgName]
+		if !ok {
+			i = len(scope)
+			orgIndex[orgName] = i
+			scope = append(scope, ui.OrgScope{Name: orgName})
+		}
+
+		scope[i].Apps++
+		if !seenSpaces[app.SpaceGuid] {
+			seenSpaces[app.SpaceGuid] = true
+			scope[i].Spaces++
+		}
+	}
 
-	waitDone.Wait()
-	close(outputsChan)
+	sort.Slice(scope, func(i, j int) bool {
+		return scope[i].Name < scope[j].Name
+	})
 
-	return outputAppsChan(outputsChan)
+	return scope
 }
 
-func generateAppsChan(apps models.Applications) chan models.Application {
+// restartable reports whether the app would actually be restarted, unlike
+// stopped apps and apps already restarted by a previous run.
+func (exe *RestartAppsExecutor) restartable(app models.Application) bool {
+	return app.State != models.Stopped && (exe.Checkpoint == nil || !exe.Checkpoint.Restarted(app.Guid))
+}
+
+// generateAppsChan hands out the apps group by group. The apps of a group are
+// only handed out once every app of the previous group finished. Dispatching
+// stops when dispatch, called with the number of apps left before each app,
+// returns false.
+func generateAppsChan(
+	ctx context.Context,
+	groups []models.Applications,
+	finished <-chan struct{},
+	dispatch func(remaining int) bool,
+) chan models.Application {
+	remaining := 0
+	for _, apps := range groups {
+		remaining += len(apps)
+	}
+
 	runningAppsChan := make(chan models.Application)
 	go func() {
 		defer close(runningAppsChan)
-		for _, app := range apps {
-			runningAppsChan <- app
+		for i, apps := range groups {
+			if i > 0 {
+				for range groups[i-1] {
+					select {
+					case <-finished:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+
+			for _, app := range apps {
+				if ctx.Err() != nil || !dispatch(remaining) {
+					return
+				}
+
+				select {
+				case runningAppsChan <- app:
+					remaining--
+				case <-ctx.Done():
+					return
+				}
+			}
 		}
 	}()
 
@@ -151,49 +584,88 @@ func generateAppsChan(apps models.Applications) chan models.Application {
 }
 
 func processAppsChan(
-	cliConnection api.Connection,
+	ctx context.Context,
+	restarter AppRestarter,
 	spaceMap map[string]models.Space,
 	restart restartAppFunc,
+	start func() bool,
 	appsChan chan models.Application,
-	outputSize int) (chan int, *sync.WaitGroup) {
+	outputSize int,
+	workers int,
+	limits *throttle) (chan appResult, *sync.WaitGroup) {
 	var waitDone sync.WaitGroup
 
-	output := make(chan int, outputSize)
+	output := make(chan appResult, outputSize)
 
-	restarter := NewAppRestarter(cliConnection)
-
-	waitDone.Add(1)
+	if workers < 1 {
+		workers = 1
+	}
 
-	go func() {
-		defer waitDone.Done()
+	if limits != nil {
+		appsChan = limits.schedule(ctx, appsChan)
+	}
 
-		for app := range appsChan {
-			a := &displayhelpers.AppPrinter{
-				App:    app,
-				Spaces: spaceMap,
+	waitDone.Add(workers)
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer waitDone.Done()
+
+			for app := range appsChan {
+				// Apps handed out just before dispatching stopped and apps
+				// that may not start anymore are left to appendNotAttempted.
+				if ctx.Err() != nil || !start() {
+					continue
+				}
+
+				result := appResult{
+					App: &displayhelpers.AppPrinter{
+						App:    app,
+						Spaces: spaceMap,
+					},
+					StartedAt: time.Now(),
+				}
+				restart(&result, restarter)
+				result.FinishedAt = time.Now()
+
+				if limits != nil {
+					limits.release(app)
+				}
+
+				output <- result
 			}
-			output <- restart(a, restarter)
-		}
-	}()
+		}()
+	}
 
 	return output, &waitDone
 }
 
-func outputAppsChan(outputsChan chan int) (int, int, int) {
-	warnings := 0
-	errors := 0
-	stopped := 0
+func outputAppsChan(outputsChan chan appResult, onResult func(ui.RestartSummary)) ([]appResult, ui.RestartSummary) {
+	var results []appResult
+	summary := ui.RestartSummary{}
 
 	for result := range outputsChan {
-		switch result {
+		results = append(results, result)
+
+		switch result.Outcome {
 		case Warning:
-			warnings++
+			summary.Warnings++
 		case Err:
-			errors++
+			summary.Errors++
 		case Stopped:
-			stopped++
+			summary.Stopped++
+		case Crashed:
+			summary.Crashed++
+		case TimedOut:
+			summary.TimedOut++
+		case Skipped:
+			summary.Skipped++
+		case StagingFailed:
+			summary.StagingFailed++
 		default:
 		}
+
+		onResult(summary)
 	}
-	return stopped, warnings, errors
+	return results, summary
 }
@@ -0,0 +1,118 @@
+package commands
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/cloudfoundry-incubator/app-restarter/commands/displayhelpers"
+	"github.com/cloudfoundry-incubator/app-restarter/models"
+
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("processAppsChan", func() {
+	var (
+		appsChan chan models.Application
+		release  chan struct{}
+		open     int32
+	)
+
+	app := func(name string) models.Application {
+		application := models.Application{}
+		application.Name = name
+		application.Guid = name + "-guid"
+		return application
+	}
+
+	appA := app("a")
+	appB := app("b")
+
+	restart := func(result *appResult, _ AppRestarter) {
+		if result.App.App.Guid == appA.Guid {
+			<-release
+		}
+		result.Outcome = Success
+	}
+
+	start := func() bool {
+		return atomic.LoadInt32(&open) == 1
+	}
+
+	restartedGuids := func(output chan appResult) []string {
+		var guids []string
+		for result := range output {
+			guids = append(guids, result.App.App.Guid)
+		}
+		return guids
+	}
+
+	ginkgo.BeforeEach(func() {
+		appsChan = make(chan models.Application)
+		release = make(chan struct{})
+		atomic.StoreInt32(&open, 1)
+	})
+
+	ginkgo.It("restarts the apps that may start", func() {
+		close(release)
+		output, waitDone := processAppsChan(context.Background(), nil, nil, restart, start, appsChan, 2, 1, nil)
+
+		appsChan <- appA
+		appsChan <- appB
+		close(appsChan)
+
+		waitDone.Wait()
+		close(output)
+		Expect(restartedGuids(output)).To(Equal([]string{appA.Guid, appB.Guid}))
+	})
+
+	ginkgo.It("checks whether an app may start once a worker takes it, not when it is handed out", func() {
+		output, waitDone := processAppsChan(context.Background(), nil, nil, restart, start, appsChan, 2, 1, nil)
+
+		appsChan <- appA
+
+		handedOut := make(chan struct{})
+		go func() {
+			appsChan <- appB
+			close(appsChan)
+			close(handedOut)
+		}()
+		Consistently(handedOut).ShouldNot(BeClosed())
+
+		atomic.StoreInt32(&open, 0)
+		close(release)
+		Eventually(handedOut).Should(BeClosed())
+
+		waitDone.Wait()
+		close(output)
+		Expect(restartedGuids(output)).To(Equal([]string{appA.Guid}))
+	})
+})
+
+// instancesRestarter only answers for the instances of an app, any other
+// call panics.
+type instancesRestarter struct {
+	AppRestarter
+	instances models.Instances
+	desired   int
+}
+
+func (r instancesRestarter) Instances(string) (models.Instances, error) {
+	return r.instances, nil
+}
+
+func (r instancesRestarter) DesiredInstances(string) (int, error) {
+	return r.desired, nil
+}
+
+var _ = ginkgo.Describe("waitForRunning", func() {
+	ginkgo.It("succeeds at once for an app scaled to zero instances", func() {
+		exe := &RestartAppsExecutor{}
+		result := &appResult{App: &displayhelpers.AppPrinter{}}
+
+		outcome, err := exe.waitForRunning(result, instancesRestarter{desired: 0}, time.Minute)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(outcome).To(Equal(Success))
+	})
+})
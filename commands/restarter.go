@@ -3,50 +3,292 @@ package commands
 import (
 	"encoding/json"
 	"errors"
+	"strings"
 
 	"github.com/cloudfoundry-incubator/app-restarter/api"
+	"github.com/cloudfoundry-incubator/app-restarter/models"
 )
 
 type AppRestarter interface {
 	Restart(string) ([]string, error)
+	Start(string) error
+	Instances(string) (models.Instances, error)
+	DesiredInstances(string) (int, error)
+	RestartInstance(appGuid string, index string) error
+	Package(string) (string, error)
+	Restage(appGuid string, packageGuid string) (string, error)
+	Staging(appGuid string, buildGuid string) (models.Staging, error)
+	UseDroplet(appGuid string, dropletGuid string) error
 }
 
 type appRestarter struct {
-	cli api.Connection
+	cli    api.Connection
+	v3     bool
+	tokens *api.TokenSource
 }
 
-func NewAppRestarter(cli api.Connection) AppRestarter {
+func NewAppRestarter(cli api.Connection, v3 bool, tokens *api.TokenSource) AppRestarter {
 	return &appRestarter{
-		cli: cli,
+		cli:    cli,
+		v3:     v3,
+		tokens: tokens,
 	}
 }
 
 func (r *appRestarter) Restart(appGuid string) ([]string, error) {
-	output, err := r.cli.CliCommandWithoutTerminalOutput("curl", "/v2/apps/"+appGuid, "-X", "PUT", "-d", `{"state":"STOPPED"}`)
+	if r.v3 {
+		return r.curl("/v3/apps/"+appGuid+"/actions/restart", "-X", "POST")
+	}
+
+	output, err := r.curl("/v2/apps/"+appGuid, "-X", "PUT", "-d", `{"state":"STOPPED"}`)
 	if err != nil {
 		return output, err
 	}
 
-	if err = checkError(output[0]); err != nil {
-		return output, err
+	output, err = r.curl("/v2/apps/"+appGuid, "-X", "PUT", "-d", `{"state":"STARTED"}`)
+	if err != nil {
+		return output, appLeftStoppedError{err: err}
+	}
+
+	return output, nil
+}
+
+func (r *appRestarter) Start(appGuid string) error {
+	if r.v3 {
+		_, err := r.curl("/v3/apps/"+appGuid+"/actions/start", "-X", "POST")
+		return err
+	}
+
+	_, err := r.curl("/v2/apps/"+appGuid, "-X", "PUT", "-d", `{"state":"STARTED"}`)
+	return err
+}
+
+func (r *appRestarter) Instances(appGuid string) (models.Instances, error) {
+	var noInstances models.Instances
+
+	path := "/v2/apps/" + appGuid + "/instances"
+	if r.v3 {
+		path = "/v3/apps/" + appGuid + "/processes/web/stats"
 	}
 
-	output, err = r.cli.CliCommandWithoutTerminalOutput("curl", "/v2/apps/"+appGuid, "-X", "PUT", "-d", `{"state":"STARTED"}`)
+	output, err := r.curl(path)
 	if err != nil {
-		return output, err
+		return noInstances, err
+	}
+
+	body := strings.Join(output, "\n")
+
+	if r.v3 {
+		return models.V3InstancesParser{}.Parse([]byte(body))
+	}
+
+	return models.InstancesParser{}.Parse([]byte(body))
+}
+
+// DesiredInstances returns the number of instances the app is scaled to,
+// which is that of its web process on v3.
+func (r *appRestarter) DesiredInstances(appGuid string) (int, error) {
+	path := "/v2/apps/" + appGuid
+	if r.v3 {
+		path = "/v3/apps/" + appGuid + "/processes/web"
+	}
+
+	output, err := r.curl(path)
+	if err != nil {
+		return 0, err
+	}
+
+	var resource struct {
+		Instances int `json:"instances"`
+		Entity    struct {
+			Instances int `json:"instances"`
+		} `json:"entity"`
+	}
+	err = json.Unmarshal([]byte(strings.Join(output, "\n")), &resource)
+	if err != nil {
+		return 0, err
+	}
+
+	if r.v3 {
+		return resource.Instances, nil
+	}
+
+	return resource.Entity.Instances, nil
+}
+
+func (r *appRestarter) RestartInstance(appGuid string, index string) error {
+	path := "/v2/apps/" + appGuid + "/instances/" + index
+	if r.v3 {
+		path = "/v3/apps/" + appGuid + "/processes/web/instances/" + index
+	}
+
+	_, err := r.curl(path, "-X", "DELETE")
+	return err
+}
+
+// Package returns the guid of the newest package of the app that is ready
+// to be staged. v2 stages the app itself, so there is none.
+func (r *appRestarter) Package(appGuid string) (string, error) {
+	if !r.v3 {
+		return "", nil
+	}
+
+	output, err := r.curl("/v3/apps/" + appGuid + "/packages?states=READY&order_by=-created_at&per_page=1")
+	if err != nil {
+		return "", err
+	}
+
+	var packages struct {
+		Resources []struct {
+			Guid string `json:"guid"`
+		} `json:"resources"`
+	}
+	err = json.Unmarshal([]byte(strings.Join(output, "\n")), &packages)
+	if err != nil {
+		return "", err
+	}
+	if len(packages.Resources) == 0 {
+		return "", errors.New("App has no package to stage")
+	}
+
+	return packages.Resources[0].Guid, nil
+}
+
+// Restage stages the app again. On v3 a build is created from the package
+// and its guid is returned.
+func (r *appRestarter) Restage(appGuid string, packageGuid string) (string, error) {
+	if !r.v3 {
+		_, err := r.curl("/v2/apps/"+appGuid+"/restage", "-X", "POST")
+		return "", err
 	}
 
-	if err = checkError(output[0]); err != nil {
+	output, err := r.curl("/v3/builds", "-X", "POST", "-d", `{"package":{"guid":"`+packageGuid+`"}}`)
+	if err != nil {
+		return "", err
+	}
+
+	var build struct {
+		Guid string `json:"guid"`
+	}
+	err = json.Unmarshal([]byte(strings.Join(output, "\n")), &build)
+	if err != nil {
+		return "", err
+	}
+
+	return build.Guid, nil
+}
+
+func (r *appRestarter) Staging(appGuid string, buildGuid string) (models.Staging, error) {
+	var noStaging models.Staging
+
+	path := "/v2/apps/" + appGuid
+	if r.v3 {
+		path = "/v3/builds/" + buildGuid
+	}
+
+	output, err := r.curl(path)
+	if err != nil {
+		return noStaging, err
+	}
+
+	body := strings.Join(output, "\n")
+
+	if r.v3 {
+		return models.V3StagingParser{}.Parse([]byte(body))
+	}
+
+	return models.StagingParser{}.Parse([]byte(body))
+}
+
+// UseDroplet makes the app run the staged droplet and restarts it on v3. On
+// v2 the app is started by the Cloud Controller once staged.
+func (r *appRestarter) UseDroplet(appGuid string, dropletGuid string) error {
+	if !r.v3 {
+		return nil
+	}
+
+	_, err := r.curl(
+		"/v3/apps/"+appGuid+"/relationships/current_droplet",
+		"-X", "PATCH",
+		"-d", `{"data":{"guid":"`+dropletGuid+`"}}`,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.curl("/v3/apps/"+appGuid+"/actions/restart", "-X", "POST")
+	return err
+}
+
+// curl runs cf curl and returns the Cloud Controller error in the response,
+// if any. When the token was rejected, the CLI is made to refresh it and the
+// request is made once more.
+func (r *appRestarter) curl(args ...string) ([]string, error) {
+	output, err := r.curlOnce(args...)
+
+	if e, ok := err.(apiError); ok && e.errorCode() == "CF-InvalidAuthToken" {
+		if _, refreshErr := r.tokens.Refresh(); refreshErr != nil {
+			return output, err
+		}
+
+		output, err = r.curlOnce(args...)
+	}
+
+	return output, err
+}
+
+func (r *appRestarter) curlOnce(args ...string) ([]string, error) {
+	output, err := r.cli.CliCommandWithoutTerminalOutput(append([]string{"curl"}, args...)...)
+	if err != nil {
 		return output, err
 	}
 
-	return output, nil
+	body := strings.Join(output, "\n")
+	if strings.TrimSpace(body) == "" {
+		return output, nil
+	}
+
+	return output, checkError(body)
+}
+
+// appLeftStoppedError is returned when an app was stopped for the restart
+// but could not be started again.
+type appLeftStoppedError struct {
+	err error
+}
+
+func (e appLeftStoppedError) Error() string {
+	return e.err.Error()
 }
 
 type apiError struct {
-	Code        int64  `json:"code,omitempty"`
-	Description string `json:"description,omitempty"`
-	ErrorCode   string `json:"error_code,omitempty"`
+	Code        int64        `json:"code,omitempty"`
+	Description string       `json:"description,omitempty"`
+	ErrorCode   string       `json:"error_code,omitempty"`
+	Errors      []v3APIError `json:"errors,omitempty"`
+}
+
+type v3APIError struct {
+	Code   int64  `json:"code"`
+	Title  string `json:"title"`
+	Detail string `json:"detail"`
+}
+
+func (e apiError) Error() string {
+	if len(e.Errors) > 0 {
+		return e.Errors[0].Title + " - " + e.Errors[0].Detail
+	}
+
+	return e.ErrorCode + " - " + e.Description
+}
+
+// errorCode returns the CF-* code of the error, which v3 reports as the title.
+func (e apiError) errorCode() string {
+	if len(e.Errors) > 0 {
+		return e.Errors[0].Title
+	}
+
+	return e.ErrorCode
 }
 
 func checkError(jsonRsp string) error {
@@ -57,8 +299,8 @@ func checkError(jsonRsp string) error {
 		return err
 	}
 
-	if theError.ErrorCode != "" || theError.Code != 0 {
-		return errors.New(theError.ErrorCode + " - " + theError.Description)
+	if len(theError.Errors) > 0 || theError.ErrorCode != "" || theError.Code != 0 {
+		return theError
 	}
 
 	return nil
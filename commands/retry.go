@@ -0,0 +1,39 @@
+package commands
+
+import (
+	"encoding/json"
+
+	"github.com/cloudfoundry-incubator/app-restarter/api"
+)
+
+// withRetries runs a call against the Cloud Controller under the retry
+// policy, counting and reporting every retry against the app.
+func (exe *RestartAppsExecutor) withRetries(result *appResult, call func() error) error {
+	return exe.Retry.Do(func() error {
+		err := call()
+		if exe.retryable(err) {
+			return api.RetryableError{Err: err}
+		}
+
+		return err
+	}, func(attempt int, err error) {
+		result.Retries++
+		exe.RestartAppsUI.RetryingEach(result.App, attempt, exe.Retry.MaxAttempts, err)
+	})
+}
+
+// retryable reports whether an error from cf curl is transient. Bodies that
+// are not JSON come from the router rather than the Cloud Controller, e.g. a
+// 502 while the Cloud Controller is restarting.
+func (exe *RestartAppsExecutor) retryable(err error) bool {
+	switch e := err.(type) {
+	case appLeftStoppedError:
+		return exe.retryable(e.err)
+	case apiError:
+		return exe.Retry.RetryableErrorCode(e.errorCode())
+	case *json.SyntaxError:
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,85 @@
+package commands
+
+import (
+	"errors"
+	"time"
+
+	"github.com/cloudfoundry-incubator/app-restarter/models"
+)
+
+// rollingRestart restarts the instances of an app one at a time, waiting for
+// each replacement to be running before moving on to the next, so that apps
+// with more than one instance keep serving traffic.
+func (exe *RestartAppsExecutor) rollingRestart(
+	result *appResult,
+	appRestarter AppRestarter,
+	timeout time.Duration,
+) (int, error) {
+	appPrinter := result.App
+
+	var instances models.Instances
+	err := exe.withRetries(result, func() error {
+		var err error
+		instances, err = appRestarter.Instances(appPrinter.App.Guid)
+		return err
+	})
+	if err != nil {
+		return Err, err
+	}
+
+	// An app scaled to zero instances has nothing to roll, while an app
+	// without the instances it is scaled to must not pass for restarted.
+	if len(instances) == 0 {
+		var desired int
+		err = exe.withRetries(result, func() error {
+			var err error
+			desired, err = appRestarter.DesiredInstances(appPrinter.App.Guid)
+			return err
+		})
+		if err != nil {
+			return Err, err
+		}
+
+		if desired == 0 {
+			return Success, nil
+		}
+
+		return Err, errors.New("App has no instances to restart")
+	}
+
+	for _, index := range instances.Indexes() {
+		exe.RestartAppsUI.RestartingInstance(appPrinter, index)
+
+		err = exe.withRetries(result, func() error {
+			return appRestarter.RestartInstance(appPrinter.App.Guid, index)
+		})
+		if err != nil {
+			return Err, err
+		}
+
+		outcome := exe.waitForInstances(appPrinter, appRestarter, timeout, instanceReplaced(index, instances[index]))
+		if outcome != Success {
+			return outcome, nil
+		}
+	}
+
+	return Success, nil
+}
+
+func instanceReplaced(index string, previous models.Instance) instancesCheck {
+	return func(instances models.Instances) (int, bool) {
+		instance, ok := instances[index]
+		if !ok {
+			return 0, false
+		}
+
+		switch {
+		case instance.State == models.InstanceCrashed || instance.State == models.InstanceFlapping:
+			return Crashed, true
+		case instance.State == models.InstanceRunning && instance.Replaces(previous):
+			return Success, true
+		}
+
+		return 0, false
+	}
+}
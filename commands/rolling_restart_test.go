@@ -0,0 +1,34 @@
+package commands
+
+import (
+	"time"
+
+	"github.com/cloudfoundry-incubator/app-restarter/commands/displayhelpers"
+
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("rollingRestart", func() {
+	var (
+		exe    *RestartAppsExecutor
+		result *appResult
+	)
+
+	ginkgo.BeforeEach(func() {
+		exe = &RestartAppsExecutor{}
+		result = &appResult{App: &displayhelpers.AppPrinter{}}
+	})
+
+	ginkgo.It("succeeds for an app scaled to zero instances, which has nothing to roll", func() {
+		outcome, err := exe.rollingRestart(result, instancesRestarter{desired: 0}, time.Minute)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(outcome).To(Equal(Success))
+	})
+
+	ginkgo.It("fails for an app without the instances it is scaled to", func() {
+		outcome, err := exe.rollingRestart(result, instancesRestarter{desired: 2}, time.Minute)
+		Expect(err).To(MatchError("App has no instances to restart"))
+		Expect(outcome).To(Equal(Err))
+	})
+})
@@ -0,0 +1,157 @@
+package commands
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry-incubator/app-restarter/commands/errorhelpers"
+	"github.com/cloudfoundry-incubator/app-restarter/models"
+)
+
+var rateUnits = map[string]time.Duration{
+	"s":   time.Second,
+	"min": time.Minute,
+	"h":   time.Hour,
+}
+
+// ParseRate parses a rate given as N/min, N/s or N/h into the time between
+// starting two restarts.
+func ParseRate(rate string) (time.Duration, error) {
+	parts := strings.Split(rate, "/")
+	if len(parts) != 2 {
+		return 0, errorhelpers.InvalidRateError
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	unit, ok := rateUnits[strings.TrimSpace(parts[1])]
+	if err != nil || n < 1 || !ok {
+		return 0, errorhelpers.InvalidRateError
+	}
+
+	return unit / time.Duration(n), nil
+}
+
+// throttle hands out apps to the workers no faster than one per interval and
+// with no more than maxPerOrg apps of an org in flight. Apps of an org that
+// is at its limit are held back while apps of other orgs go ahead.
+type throttle struct {
+	interval  time.Duration
+	maxPerOrg int
+	apps      int
+	orgOf     func(models.Application) string
+	released  chan string
+
+	// restarts reports whether an app is actually restarted. Apps that are
+	// not, e.g. stopped apps, are handed out without taking up a slot.
+	restarts func(models.Application) bool
+
+	// dispatch is called with the number of apps not handed out yet right
+	// before each app is handed out, and stops scheduling when it returns
+	// false. Apps held back are only checked once they go ahead.
+	dispatch func(remaining int) bool
+}
+
+// newThrottle returns nil when neither limit is set.
+func newThrottle(interval time.Duration, maxPerOrg int, spaceMap map[string]models.Space, apps int) *throttle {
+	if interval <= 0 && maxPerOrg <= 0 {
+		return nil
+	}
+
+	return &throttle{
+		interval:  interval,
+		maxPerOrg: maxPerOrg,
+		apps:      apps,
+		orgOf: func(app models.Application) string {
+			return spaceMap[app.SpaceGuid].OrganizationGuid
+		},
+		// Releases never block, even once scheduling stopped.
+		released: make(chan string, apps),
+	}
+}
+
+// schedule only takes the next app from in when none of the apps held back
+// can go ahead, so that the apps are still handed out in order where the
+// limits allow it.
+func (t *throttle) schedule(ctx context.Context, in chan models.Application) chan models.Application {
+	out := make(chan models.Application)
+
+	go func() {
+		defer close(out)
+
+		var pending []models.Application
+		inFlight := map[string]int{}
+		orgOfInFlight := map[string]string{}
+		var nextStart time.Time
+		remaining := t.apps
+
+		for {
+			if in == nil && len(pending) == 0 {
+				return
+			}
+
+			next := -1
+			for i, app := range pending {
+				if !t.limited(app) || t.maxPerOrg <= 0 || inFlight[t.orgOf(app)] < t.maxPerOrg {
+					next = i
+					break
+				}
+			}
+
+			var receive chan models.Application
+			var send chan models.Application
+			var ready <-chan time.Time
+			var candidate models.Application
+
+			switch {
+			case next < 0:
+				receive = in
+			case t.limited(pending[next]) && time.Now().Before(nextStart):
+				ready = time.After(time.Until(nextStart))
+			default:
+				if ctx.Err() != nil || (t.dispatch != nil && !t.dispatch(remaining)) {
+					return
+				}
+				send = out
+				candidate = pending[next]
+			}
+
+			select {
+			case app, ok := <-receive:
+				if !ok {
+					in = nil
+					continue
+				}
+				pending = append(pending, app)
+			case guid := <-t.released:
+				if org, ok := orgOfInFlight[guid]; ok {
+					inFlight[org]--
+					delete(orgOfInFlight, guid)
+				}
+			case <-ready:
+			case send <- candidate:
+				pending = append(pending[:next], pending[next+1:]...)
+				if t.limited(candidate) {
+					org := t.orgOf(candidate)
+					inFlight[org]++
+					orgOfInFlight[candidate.Guid] = org
+					nextStart = time.Now().Add(t.interval)
+				}
+				remaining--
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out
+}
+
+func (t *throttle) limited(app models.Application) bool {
+	return t.restarts == nil || t.restarts(app)
+}
+
+func (t *throttle) release(app models.Application) {
+	t.released <- app.Guid
+}
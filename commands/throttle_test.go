@@ -0,0 +1,187 @@
+package commands
+
+import (
+	"context"
+	"time"
+
+	"github.com/cloudfoundry-incubator/app-restarter/commands/errorhelpers"
+	"github.com/cloudfoundry-incubator/app-restarter/models"
+
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("Throttle", func() {
+	table.DescribeTable("ParseRate",
+		func(rate string, interval time.Duration) {
+			Expect(ParseRate(rate)).To(Equal(interval))
+		},
+		table.Entry("per second", "4/s", 250*time.Millisecond),
+		table.Entry("per minute", "10/min", 6*time.Second),
+		table.Entry("per hour", "2/h", 30*time.Minute),
+		table.Entry("with spaces", " 10 / min ", 6*time.Second),
+	)
+
+	table.DescribeTable("ParseRate with an invalid rate",
+		func(rate string) {
+			_, err := ParseRate(rate)
+			Expect(err).To(Equal(errorhelpers.InvalidRateError))
+		},
+		table.Entry("without a unit", "10"),
+		table.Entry("with an unknown unit", "10/day"),
+		table.Entry("with a count of zero", "0/min"),
+		table.Entry("with a count that is not a number", "ten/min"),
+		table.Entry("with too many parts", "10/min/s"),
+	)
+
+	ginkgo.Describe("schedule", func() {
+		var (
+			ctx    context.Context
+			cancel context.CancelFunc
+			in     chan models.Application
+			out    chan models.Application
+			limits *throttle
+		)
+
+		app := func(name string, spaceGuid string) models.Application {
+			application := models.Application{}
+			application.Name = name
+			application.Guid = name + "-guid"
+			application.SpaceGuid = spaceGuid
+			return application
+		}
+
+		spaceMap := map[string]models.Space{}
+		for _, space := range []struct{ guid, org string }{{"space-1", "org-1"}, {"space-2", "org-2"}} {
+			s := models.Space{}
+			s.Guid = space.guid
+			s.OrganizationGuid = space.org
+			spaceMap[space.guid] = s
+		}
+
+		appA := app("a", "space-1")
+		appB := app("b", "space-1")
+		appC := app("c", "space-2")
+
+		ginkgo.BeforeEach(func() {
+			ctx, cancel = context.WithCancel(context.Background())
+
+			in = make(chan models.Application, 3)
+			in <- appA
+			in <- appB
+			in <- appC
+			close(in)
+		})
+
+		ginkgo.AfterEach(func() {
+			cancel()
+		})
+
+		ginkgo.Context("with a limit per org", func() {
+			ginkgo.BeforeEach(func() {
+				limits = newThrottle(0, 1, spaceMap, 3)
+			})
+
+			ginkgo.It("holds back the apps of an org at its limit while other orgs go ahead", func() {
+				out = limits.schedule(ctx, in)
+
+				Eventually(out).Should(Receive(Equal(appA)))
+				Eventually(out).Should(Receive(Equal(appC)))
+				Consistently(out).ShouldNot(Receive())
+
+				limits.release(appA)
+				Eventually(out).Should(Receive(Equal(appB)))
+				Eventually(out).Should(BeClosed())
+			})
+
+			ginkgo.It("checks dispatch right before an app held back goes ahead", func() {
+				var remainings []int
+				open := true
+				limits.dispatch = func(remaining int) bool {
+					remainings = append(remainings, remaining)
+					return open
+				}
+
+				out = limits.schedule(ctx, in)
+
+				Eventually(out).Should(Receive(Equal(appA)))
+				Eventually(out).Should(Receive(Equal(appC)))
+				Consistently(out).ShouldNot(Receive())
+
+				open = false
+				limits.release(appA)
+				Eventually(out).Should(BeClosed())
+				Expect(remainings).To(Equal([]int{3, 2, 1}))
+			})
+
+			ginkgo.It("stops once the context is cancelled", func() {
+				out = limits.schedule(ctx, in)
+
+				Eventually(out).Should(Receive(Equal(appA)))
+				Eventually(out).Should(Receive(Equal(appC)))
+
+				cancel()
+				limits.release(appA)
+				Eventually(out).Should(BeClosed())
+			})
+		})
+
+		ginkgo.Context("with a rate", func() {
+			ginkgo.BeforeEach(func() {
+				limits = newThrottle(100*time.Millisecond, 0, spaceMap, 3)
+			})
+
+			ginkgo.It("hands out the apps in order no faster than the rate", func() {
+				out = limits.schedule(ctx, in)
+
+				var received []models.Application
+				var times []time.Time
+				for application := range out {
+					received = append(received, application)
+					times = append(times, time.Now())
+				}
+
+				Expect(received).To(Equal([]models.Application{appA, appB, appC}))
+				Expect(times[1].Sub(times[0])).To(BeNumerically(">=", 90*time.Millisecond))
+				Expect(times[2].Sub(times[1])).To(BeNumerically(">=", 90*time.Millisecond))
+			})
+		})
+
+		ginkgo.Context("with apps that are not restarted", func() {
+			ginkgo.BeforeEach(func() {
+				limits = newThrottle(100*time.Millisecond, 1, spaceMap, 3)
+				limits.restarts = func(application models.Application) bool {
+					return application.Guid != appB.Guid
+				}
+			})
+
+			ginkgo.It("hands them out without taking up a rate or an org slot", func() {
+				appD := app("d", "space-1")
+				apps := make(chan models.Application, 3)
+				apps <- appA
+				apps <- appB
+				apps <- appD
+				close(apps)
+
+				started := time.Now()
+				out = limits.schedule(ctx, apps)
+
+				Eventually(out).Should(Receive(Equal(appA)))
+				Eventually(out).Should(Receive(Equal(appB)))
+				Expect(time.Since(started)).To(BeNumerically("<", 90*time.Millisecond))
+
+				limits.release(appB)
+				Consistently(out, 200*time.Millisecond).ShouldNot(Receive())
+
+				limits.release(appA)
+				Eventually(out).Should(Receive(Equal(appD)))
+				Eventually(out).Should(BeClosed())
+			})
+		})
+
+		ginkgo.It("is not needed without limits", func() {
+			Expect(newThrottle(0, 0, spaceMap, 3)).To(BeNil())
+		})
+	})
+})
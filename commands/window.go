@@ -0,0 +1,104 @@
+package commands
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry-incubator/app-restarter/commands/errorhelpers"
+)
+
+// MaintenanceWindow is a daily time span in which apps may be restarted. A
+// window whose end is before its start spans midnight.
+type MaintenanceWindow struct {
+	start    int
+	end      int
+	location *time.Location
+}
+
+// NewMaintenanceWindow parses a window given as START-END in 24 hour HH:MM
+// times in the timezone, e.g. 02:00-05:00 in Europe/Berlin. The local
+// timezone is used when none is given.
+func NewMaintenanceWindow(window string, timezone string) (*MaintenanceWindow, error) {
+	location := time.Local
+	if timezone != "" {
+		var err error
+		location, err = time.LoadLocation(timezone)
+		if err != nil {
+			return nil, errorhelpers.InvalidTimezoneError
+		}
+	}
+
+	parts := strings.Split(window, "-")
+	if len(parts) != 2 {
+		return nil, errorhelpers.InvalidWindowError
+	}
+
+	start, err := time.Parse("15:04", strings.TrimSpace(parts[0]))
+	if err != nil {
+		return nil, errorhelpers.InvalidWindowError
+	}
+
+	end, err := time.Parse("15:04", strings.TrimSpace(parts[1]))
+	if err != nil || end.Equal(start) {
+		return nil, errorhelpers.InvalidWindowError
+	}
+
+	return &MaintenanceWindow{
+		start:    minuteOfDay(start),
+		end:      minuteOfDay(end),
+		location: location,
+	}, nil
+}
+
+func (w *MaintenanceWindow) Open(now time.Time) bool {
+	minute := minuteOfDay(now.In(w.location))
+
+	if w.start < w.end {
+		return minute >= w.start && minute < w.end
+	}
+
+	return minute >= w.start || minute < w.end
+}
+
+// NextOpen returns when the window opens next after now.
+func (w *MaintenanceWindow) NextOpen(now time.Time) time.Time {
+	now = now.In(w.location)
+
+	next := time.Date(now.Year(), now.Month(), now.Day(), w.start/60, w.start%60, 0, 0, w.location)
+	if !next.After(now) {
+		next = time.Date(now.Year(), now.Month(), now.Day()+1, w.start/60, w.start%60, 0, 0, w.location)
+	}
+
+	return next
+}
+
+// minuteOfDay goes by the clock, so that the window keeps its times when
+// daylight saving time starts or ends.
+func minuteOfDay(t time.Time) int {
+	return t.Hour()*60 + t.Minute()
+}
+
+// waitForWindow is called before each app is handed out and again before it
+// starts, and reports whether to go on. Outside the window it either waits for the window to open again
+// or stops dispatching when ExitOutsideWindow is set.
+func (exe *RestartAppsExecutor) waitForWindow(ctx context.Context, remaining int) bool {
+	if exe.Window == nil || exe.Window.Open(time.Now()) {
+		return true
+	}
+
+	if exe.ExitOutsideWindow {
+		exe.RestartAppsUI.WindowClosed(remaining)
+		return false
+	}
+
+	next := exe.Window.NextOpen(time.Now())
+	exe.RestartAppsUI.WaitingForWindow(next, remaining)
+
+	select {
+	case <-time.After(time.Until(next)):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,65 @@
+package commands_test
+
+import (
+	"time"
+
+	"github.com/cloudfoundry-incubator/app-restarter/commands"
+	"github.com/cloudfoundry-incubator/app-restarter/commands/errorhelpers"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("MaintenanceWindow", func() {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		panic(err)
+	}
+
+	at := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2026, month, day, hour, minute, 0, 0, berlin)
+	}
+
+	table.DescribeTable("NewMaintenanceWindow with invalid options",
+		func(window string, timezone string, expected error) {
+			_, err := commands.NewMaintenanceWindow(window, timezone)
+			Expect(err).To(Equal(expected))
+		},
+		table.Entry("without an end", "02:00", "", errorhelpers.InvalidWindowError),
+		table.Entry("with an invalid start", "2am-05:00", "", errorhelpers.InvalidWindowError),
+		table.Entry("with an invalid end", "02:00-25:00", "", errorhelpers.InvalidWindowError),
+		table.Entry("with the same start and end", "02:00-02:00", "", errorhelpers.InvalidWindowError),
+		table.Entry("with an unknown timezone", "02:00-05:00", "Mars/Olympus_Mons", errorhelpers.InvalidTimezoneError),
+	)
+
+	table.DescribeTable("Open",
+		func(window string, now time.Time, open bool) {
+			w, err := commands.NewMaintenanceWindow(window, "Europe/Berlin")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(w.Open(now)).To(Equal(open))
+		},
+		table.Entry("before the window", "02:00-05:00", at(time.March, 10, 1, 59), false),
+		table.Entry("at its start", "02:00-05:00", at(time.March, 10, 2, 0), true),
+		table.Entry("within the window", "02:00-05:00", at(time.March, 10, 4, 59), true),
+		table.Entry("at its end", "02:00-05:00", at(time.March, 10, 5, 0), false),
+		table.Entry("before midnight in a window across midnight", "22:00-03:00", at(time.March, 10, 23, 30), true),
+		table.Entry("after midnight in a window across midnight", "22:00-03:00", at(time.March, 10, 2, 30), true),
+		table.Entry("outside a window across midnight", "22:00-03:00", at(time.March, 10, 12, 0), false),
+		table.Entry("at the end of a window across midnight", "22:00-03:00", at(time.March, 10, 3, 0), false),
+		table.Entry("in another timezone", "02:00-05:00", time.Date(2026, time.March, 10, 1, 30, 0, 0, time.UTC), true),
+	)
+
+	table.DescribeTable("NextOpen",
+		func(window string, now time.Time, next time.Time) {
+			w, err := commands.NewMaintenanceWindow(window, "Europe/Berlin")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(w.NextOpen(now)).To(BeTemporally("==", next))
+		},
+		table.Entry("later the same day", "02:00-05:00", at(time.March, 10, 1, 0), at(time.March, 10, 2, 0)),
+		table.Entry("the next day once the window closed", "02:00-05:00", at(time.March, 10, 6, 0), at(time.March, 11, 2, 0)),
+		table.Entry("the next day at its start", "02:00-05:00", at(time.March, 10, 2, 0), at(time.March, 11, 2, 0)),
+		table.Entry("before midnight for a window across midnight", "22:00-03:00", at(time.March, 10, 12, 0), at(time.March, 10, 22, 0)),
+		table.Entry("by the clock when daylight saving time starts", "02:30-05:00", at(time.March, 28, 12, 0), time.Date(2026, time.March, 29, 3, 30, 0, 0, berlin)),
+	)
+})
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"os"
 
 	"github.com/cloudfoundry-incubator/app-restarter/commands"
@@ -25,11 +26,61 @@ func (c *AppRestarter) GetMetadata() plugin.PluginMetadata {
 				Name:     "restart-apps",
 				HelpText: "Restart all apps",
 				UsageDetails: plugin.Usage{
-					Usage: `cf restart-apps [-o ORG | -s SPACE]
+					Usage: `cf restart-apps [-o ORG]... [-s SPACE | -s ORG/SPACE]... [--app-pattern REGEX] [--exclude-pattern REGEX]
+   [--exclude-org ORG] [--exclude-space SPACE] [--buildpack NAME]... [--stack NAME]... [--apps-file PATH]
+   [--parallel N] [--dry-run] [-f] [--api-version auto|v2|v3]
+   [--rolling | --strategy restart|restage] [--canary N [--canary-wait DURATION]] [--state-file PATH [--resume]]
+   [--output text|json] [--report-file PATH]
+   [--window START-END [--timezone TZ] [--exit-outside-window]]
+   [--rate N/min] [--max-per-org N]
+   [--max-failures N] [--max-failure-percent P] [--fail-on-warning]
+   [--order-by name|space|org|created|memory] [--reverse] [--group-by-space]
+   [--retry-attempts N] [--retry-backoff DURATION] [--retry-max-backoff DURATION] [--retry-jitter FRACTION]
+   [--retry-status CODE]... [--retry-error-code CODE]...
 
 OPTIONS:
-   -o      Organization to restrict the app restarts
-   -s      Space in the targeted organization to restrict the app restarts`,
+   -o                   Organization to restrict the app restarts, can be repeated
+   -s                   Space in the targeted organization or ORG/SPACE to restrict the app restarts, can be repeated
+   --app-pattern        Only restart apps with a name matching the pattern, can be repeated
+   --exclude-pattern    Do not restart apps with a name matching the pattern, can be repeated
+   --exclude-org        Do not restart apps in the organization, can be repeated
+   --exclude-space      Do not restart apps in the space of the targeted organization or ORG/SPACE, can be repeated
+   --buildpack          Only restart apps pushed with or detected to use the buildpack, can be repeated
+   --stack              Only restart apps running on the stack, can be repeated
+   --apps-file          File listing the apps to restart by GUID or ORG/SPACE/APP, one per line, or - for stdin
+   --parallel           Number of apps to restart concurrently (Default: 1)
+   --dry-run            Print the apps that would be restarted without restarting them
+   --force, -f          Restart the apps without asking for confirmation, also after the canary apps
+   --api-version        Cloud Controller API version to use (Default: auto)
+   --rolling            Restart the instances of each app one at a time instead of stopping and starting the whole app
+   --strategy           Restart the apps, or restage them to pick up new buildpacks and stacks (Default: restart)
+   --state-file         File to record the outcome of each app in as it completes
+   --resume             Skip apps recorded as restarted in the state file by a previous run
+   --output             Output format of the run, json prints a report of every app to stdout (Default: text)
+   --report-file        File to write a JSON report of every app to
+   --canary             Restart N apps first and only go on with the remaining apps once all of them are healthy
+   --canary-wait        Time to wait after the canary apps before going on, instead of asking for confirmation
+   --window             Only restart apps between the HH:MM times, e.g. 02:00-05:00
+   --timezone           Timezone of the window, e.g. Europe/Berlin, the local timezone by default
+   --exit-outside-window
+                        Exit when the window closes instead of waiting for it to open again
+   --rate               Start restarting no more than N apps per minute, or per second or hour with N/s or N/h
+   --max-per-org        Restart no more than N apps of an org at the same time
+   --max-failures       Stop restarting further apps once N apps failed
+   --max-failure-percent
+                        Stop restarting further apps once P percent of all apps failed
+   --fail-on-warning    Exit with an error when apps could not be restarted for lack of authorization, too
+   --order-by           Restart the apps ordered by name, space, org, creation time or memory, in the order they
+                        are listed by default
+   --reverse            Restart the apps in reverse order, requires --order-by
+   --group-by-space     Finish restarting the apps of a space before going on with the next space
+   --retry-attempts     Number of times to try a Cloud Controller request that fails with a transient error (Default: 3)
+   --retry-backoff      Time to wait before the first retry, doubled for every further retry (Default: 2s)
+   --retry-max-backoff  Maximum time to wait between retries (Default: 30s)
+   --retry-jitter       Fraction by which to randomly vary the time between retries (Default: 0.2)
+   --retry-status       HTTP status code to retry, can be repeated (Default: 429, 502, 503, 504)
+   --retry-error-code   Cloud Controller error code to retry, can be repeated (Default: CF-AsyncServiceInProgress,
+                        CF-ServiceUnavailable, CF-RunnerUnavailable, CF-StagerUnavailable, CF-InstancesUnavailable)`,
 				},
 			},
 		},
@@ -43,13 +94,21 @@ func main() {
 func (c *AppRestarter) Run(cliConnection plugin.CliConnection, args []string) {
 	commands.Context.CLIConnection = cliConnection
 
-	parser := flags.NewParser(&commands.AppRestarterContext{}, flags.HelpFlag|flags.PassDoubleDash)
+	options := &commands.AppRestarterContext{}
+	parser := flags.NewParser(options, flags.HelpFlag|flags.PassDoubleDash)
 	parser.NamespaceDelimiter = "-"
 
 	_, err := parser.ParseArgs(args)
 	if err != nil {
-		ui.SayFailed()
-		fmt.Printf("Error: %s\n", err.Error())
+		// With --output json stdout only holds the report, so that it can
+		// be parsed even when the run fails.
+		var out io.Writer = os.Stdout
+		if options.RestartApps.Output == "json" {
+			out = os.Stderr
+		}
+
+		ui.SayFailed(out)
+		fmt.Fprintf(out, "Error: %s\n", err.Error())
 		os.Exit(1)
 	}
 }
@@ -1,11 +1,15 @@
 package models
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"time"
+)
 
 type Applications []Application
 
 type ApplicationMetadata struct {
-	Guid string `json:"guid"`
+	Guid      string    `json:"guid"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
 const (
@@ -18,6 +22,16 @@ type ApplicationEntity struct {
 	Diego     bool
 	State     string `json:"state"`
 	SpaceGuid string `json:"space_guid"`
+	Memory    int    `json:"memory"`
+
+	Buildpack         string    `json:"buildpack"`
+	DetectedBuildpack string    `json:"detected_buildpack"`
+	StackGuid         string    `json:"stack_guid"`
+	PackageUpdatedAt  time.Time `json:"package_updated_at"`
+
+	// StackName is only known on v3, which names the stack of an app
+	// instead of referring to it by guid.
+	StackName string `json:"-"`
 }
 
 type ApplicationsResponse struct {
@@ -42,3 +56,79 @@ func (a ApplicationsParser) Parse(body []byte) (Applications, error) {
 
 	return response.Resources, nil
 }
+
+type v3ApplicationsResponse struct {
+	Resources []v3Application `json:"resources"`
+}
+
+type v3Application struct {
+	Guid          string    `json:"guid"`
+	Name          string    `json:"name"`
+	State         string    `json:"state"`
+	CreatedAt     time.Time `json:"created_at"`
+	Relationships struct {
+		Space struct {
+			Data struct {
+				Guid string `json:"guid"`
+			} `json:"data"`
+		} `json:"space"`
+	} `json:"relationships"`
+	Lifecycle struct {
+		Data struct {
+			Buildpacks []string `json:"buildpacks"`
+			Stack      string   `json:"stack"`
+		} `json:"data"`
+	} `json:"lifecycle"`
+}
+
+// ApplicationParser parses a single app as returned by /v2/apps/:guid.
+type ApplicationParser struct{}
+
+func (a ApplicationParser) Parse(body []byte) (Application, error) {
+	var application Application
+
+	err := json.Unmarshal(body, &application)
+	if err != nil {
+		return Application{}, err
+	}
+
+	return application, nil
+}
+
+type V3ApplicationsParser struct{}
+
+func (a V3ApplicationsParser) Parse(body []byte) (Applications, error) {
+	var response v3ApplicationsResponse
+	var emptyApplications Applications
+
+	err := json.Unmarshal(body, &response)
+	if err != nil {
+		return emptyApplications, err
+	}
+
+	var applications Applications
+	for _, resource := range response.Resources {
+		// The last buildpack is the one that runs the app.
+		var buildpack string
+		if buildpacks := resource.Lifecycle.Data.Buildpacks; len(buildpacks) > 0 {
+			buildpack = buildpacks[len(buildpacks)-1]
+		}
+
+		applications = append(applications, Application{
+			ApplicationEntity: ApplicationEntity{
+				Name:      resource.Name,
+				Diego:     true,
+				State:     resource.State,
+				SpaceGuid: resource.Relationships.Space.Data.Guid,
+				Buildpack: buildpack,
+				StackName: resource.Lifecycle.Data.Stack,
+			},
+			ApplicationMetadata: ApplicationMetadata{
+				Guid:      resource.Guid,
+				CreatedAt: resource.CreatedAt,
+			},
+		})
+	}
+
+	return applications, nil
+}
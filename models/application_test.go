@@ -1,6 +1,8 @@
 package models_test
 
 import (
+	"time"
+
 	. "github.com/cloudfoundry-incubator/app-restarter/models"
 
 	. "github.com/onsi/ginkgo"
@@ -119,6 +121,87 @@ var _ = Describe("Application", func() {
 			Expect(applications[0].SpaceGuid).To(Equal("1f7ac3a5-6f4e-4d6c-8edd-ce694fc8c907"))
 			Expect(applications[0].Guid).To(Equal("b2ba6466-23f7-4f90-935b-4da1c87b8943"))
 			Expect(applications[0].State).To(Equal(Started))
+			Expect(applications[0].Memory).To(Equal(512))
+			Expect(applications[0].Buildpack).To(BeEmpty())
+			Expect(applications[0].DetectedBuildpack).To(Equal("staticfile 1.3.1"))
+			Expect(applications[0].StackGuid).To(Equal("f3cecf19-4567-4dca-ad35-2a3af733cbde"))
+			Expect(applications[0].PackageUpdatedAt).To(Equal(time.Date(2016, 3, 16, 16, 41, 55, 0, time.UTC)))
+			Expect(applications[0].CreatedAt).To(Equal(time.Date(2016, 3, 16, 16, 40, 43, 0, time.UTC)))
+		})
+	})
+
+	Describe("ApplicationParser", func() {
+		It("parses a single app", func() {
+			application, err := ApplicationParser{}.Parse([]byte(`{
+   "metadata": {
+      "guid": "b2ba6466-23f7-4f90-935b-4da1c87b8943",
+      "created_at": "2016-03-16T16:40:43Z"
+   },
+   "entity": {
+      "name": "ilovedogs",
+      "space_guid": "1f7ac3a5-6f4e-4d6c-8edd-ce694fc8c907",
+      "state": "STARTED",
+      "memory": 512
+   }
+}`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(application.Guid).To(Equal("b2ba6466-23f7-4f90-935b-4da1c87b8943"))
+			Expect(application.Name).To(Equal("ilovedogs"))
+			Expect(application.SpaceGuid).To(Equal("1f7ac3a5-6f4e-4d6c-8edd-ce694fc8c907"))
+			Expect(application.State).To(Equal(Started))
+			Expect(application.Memory).To(Equal(512))
+		})
+	})
+
+	Describe("V3Parser", func() {
+		jsonBody := `{
+   "pagination": {
+      "total_results": 1,
+      "total_pages": 1,
+      "first": { "href": "https://api.example.org/v3/apps?page=1&per_page=50" },
+      "last": { "href": "https://api.example.org/v3/apps?page=1&per_page=50" },
+      "next": null,
+      "previous": null
+   },
+   "resources": [
+      {
+         "guid": "b2ba6466-23f7-4f90-935b-4da1c87b8943",
+         "name": "ilovedogs",
+         "state": "STARTED",
+         "created_at": "2016-03-16T16:40:43Z",
+         "updated_at": "2016-03-16T16:42:01Z",
+         "lifecycle": {
+            "type": "buildpack",
+            "data": {
+               "buildpacks": ["staticfile_buildpack"],
+               "stack": "cflinuxfs3"
+            }
+         },
+         "relationships": {
+            "space": {
+               "data": {
+                  "guid": "1f7ac3a5-6f4e-4d6c-8edd-ce694fc8c907"
+               }
+            }
+         },
+         "links": {
+            "self": { "href": "https://api.example.org/v3/apps/b2ba6466-23f7-4f90-935b-4da1c87b8943" }
+         }
+      }
+   ]
+}`
+
+		It("parses", func() {
+			applications, err := V3ApplicationsParser{}.Parse([]byte(jsonBody))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(applications).To(HaveLen(1))
+			Expect(applications[0].Name).To(Equal("ilovedogs"))
+			Expect(applications[0].SpaceGuid).To(Equal("1f7ac3a5-6f4e-4d6c-8edd-ce694fc8c907"))
+			Expect(applications[0].Guid).To(Equal("b2ba6466-23f7-4f90-935b-4da1c87b8943"))
+			Expect(applications[0].State).To(Equal(Started))
+			Expect(applications[0].CreatedAt).To(Equal(time.Date(2016, 3, 16, 16, 40, 43, 0, time.UTC)))
+			Expect(applications[0].Buildpack).To(Equal("staticfile_buildpack"))
+			Expect(applications[0].StackName).To(Equal("cflinuxfs3"))
 		})
 	})
 })
@@ -0,0 +1,38 @@
+package models
+
+import "encoding/json"
+
+// Droplet tells which buildpack staged an app. Only v3 has droplets.
+type Droplet struct {
+	Buildpack         string
+	DetectedBuildpack string
+}
+
+type v3Droplet struct {
+	Buildpacks []struct {
+		Name         string `json:"name"`
+		DetectOutput string `json:"detect_output"`
+	} `json:"buildpacks"`
+}
+
+type DropletParser struct{}
+
+func (p DropletParser) Parse(body []byte) (Droplet, error) {
+	var droplet v3Droplet
+
+	err := json.Unmarshal(body, &droplet)
+	if err != nil {
+		return Droplet{}, err
+	}
+
+	// The last buildpack is the one that runs the app.
+	if len(droplet.Buildpacks) == 0 {
+		return Droplet{}, nil
+	}
+	buildpack := droplet.Buildpacks[len(droplet.Buildpacks)-1]
+
+	return Droplet{
+		Buildpack:         buildpack.Name,
+		DetectedBuildpack: buildpack.DetectOutput,
+	}, nil
+}
@@ -0,0 +1,43 @@
+package models_test
+
+import (
+	. "github.com/cloudfoundry-incubator/app-restarter/models"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Droplet", func() {
+	Describe("Parser", func() {
+		It("parses the buildpack that runs the app", func() {
+			droplet, err := DropletParser{}.Parse([]byte(`{
+   "guid": "585bc3c1-3743-497d-88b0-403ad6b56d16",
+   "state": "STAGED",
+   "buildpacks": [
+      {
+         "name": "nodejs_buildpack",
+         "detect_output": "nodejs",
+         "buildpack_name": "nodejs",
+         "version": "1.7.0"
+      },
+      {
+         "name": "staticfile_buildpack",
+         "detect_output": "staticfile",
+         "buildpack_name": "staticfile",
+         "version": "1.5.0"
+      }
+   ],
+   "stack": "cflinuxfs3"
+}`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(droplet.Buildpack).To(Equal("staticfile_buildpack"))
+			Expect(droplet.DetectedBuildpack).To(Equal("staticfile"))
+		})
+
+		It("parses a droplet without buildpacks", func() {
+			droplet, err := DropletParser{}.Parse([]byte(`{"state": "STAGED", "buildpacks": []}`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(droplet).To(Equal(Droplet{}))
+		})
+	})
+})
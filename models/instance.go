@@ -0,0 +1,114 @@
+package models
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+)
+
+const (
+	InstanceRunning  = "RUNNING"
+	InstanceStarting = "STARTING"
+	InstanceCrashed  = "CRASHED"
+	InstanceFlapping = "FLAPPING"
+	InstanceDown     = "DOWN"
+)
+
+type Instance struct {
+	State  string  `json:"state"`
+	Since  float64 `json:"since"`
+	Uptime int64   `json:"uptime"`
+}
+
+// Replaces reports whether the instance is a newer incarnation of previous,
+// going by its start time on v2 and by its uptime on v3.
+func (i Instance) Replaces(previous Instance) bool {
+	if i.Since != 0 || previous.Since != 0 {
+		return i.Since > previous.Since
+	}
+
+	return i.Uptime < previous.Uptime
+}
+
+type Instances map[string]Instance
+
+func (i Instances) Indexes() []string {
+	var indexes []string
+	for index := range i {
+		indexes = append(indexes, index)
+	}
+
+	sort.Slice(indexes, func(a, b int) bool {
+		left, _ := strconv.Atoi(indexes[a])
+		right, _ := strconv.Atoi(indexes[b])
+		return left < right
+	})
+
+	return indexes
+}
+
+// Running reports whether the desired number of instances is running. Right
+// after a start the Cloud Controller reports fewer instances or none yet.
+func (i Instances) Running(desired int) bool {
+	if len(i) < desired {
+		return false
+	}
+
+	for _, instance := range i {
+		if instance.State != InstanceRunning {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (i Instances) Crashed() bool {
+	for _, instance := range i {
+		if instance.State == InstanceCrashed || instance.State == InstanceFlapping {
+			return true
+		}
+	}
+
+	return false
+}
+
+type InstancesParser struct{}
+
+func (p InstancesParser) Parse(body []byte) (Instances, error) {
+	var instances Instances
+	var emptyInstances Instances
+
+	err := json.Unmarshal(body, &instances)
+	if err != nil {
+		return emptyInstances, err
+	}
+
+	return instances, nil
+}
+
+type v3ProcessStatsResponse struct {
+	Resources []struct {
+		Index int `json:"index"`
+		Instance
+	} `json:"resources"`
+}
+
+type V3InstancesParser struct{}
+
+func (p V3InstancesParser) Parse(body []byte) (Instances, error) {
+	var response v3ProcessStatsResponse
+	var emptyInstances Instances
+
+	err := json.Unmarshal(body, &response)
+	if err != nil {
+		return emptyInstances, err
+	}
+
+	instances := make(Instances)
+	for _, resource := range response.Resources {
+		instances[strconv.Itoa(resource.Index)] = resource.Instance
+	}
+
+	return instances, nil
+}
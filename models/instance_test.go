@@ -0,0 +1,146 @@
+package models_test
+
+import (
+	. "github.com/cloudfoundry-incubator/app-restarter/models"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Instance", func() {
+	Describe("Parser", func() {
+		jsonBody := `{
+   "0": {
+      "state": "RUNNING",
+      "since": 1458233226.5463457
+   },
+   "1": {
+      "state": "STARTING",
+      "since": 1458233241.1234567
+   }
+}`
+
+		It("parses", func() {
+			instances, err := InstancesParser{}.Parse([]byte(jsonBody))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(instances).To(HaveLen(2))
+			Expect(instances["0"].State).To(Equal(InstanceRunning))
+			Expect(instances["1"].State).To(Equal(InstanceStarting))
+			Expect(instances["0"].Since).To(BeNumerically("~", 1458233226.5, 0.1))
+		})
+
+		It("returns an error for a Cloud Controller error body", func() {
+			_, err := InstancesParser{}.Parse([]byte(`{"code":170002,"error_code":"CF-NotStaged"}`))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("Running", func() {
+		It("is true when every instance is running", func() {
+			instances := Instances{
+				"0": {State: InstanceRunning},
+				"1": {State: InstanceRunning},
+			}
+			Expect(instances.Running(2)).To(BeTrue())
+		})
+
+		It("is false when any instance is not running yet", func() {
+			instances := Instances{
+				"0": {State: InstanceRunning},
+				"1": {State: InstanceStarting},
+			}
+			Expect(instances.Running(2)).To(BeFalse())
+		})
+
+		It("is false when fewer instances than desired are reported yet", func() {
+			instances := Instances{
+				"0": {State: InstanceRunning},
+			}
+			Expect(instances.Running(2)).To(BeFalse())
+			Expect(Instances{}.Running(1)).To(BeFalse())
+		})
+
+		It("is true for an app scaled to zero instances", func() {
+			Expect(Instances{}.Running(0)).To(BeTrue())
+		})
+	})
+
+	Describe("Crashed", func() {
+		It("is true when any instance crashed", func() {
+			instances := Instances{
+				"0": {State: InstanceRunning},
+				"1": {State: InstanceCrashed},
+			}
+			Expect(instances.Crashed()).To(BeTrue())
+		})
+
+		It("is true when any instance is flapping", func() {
+			instances := Instances{
+				"0": {State: InstanceFlapping},
+			}
+			Expect(instances.Crashed()).To(BeTrue())
+		})
+
+		It("is false when no instance crashed", func() {
+			instances := Instances{
+				"0": {State: InstanceStarting},
+			}
+			Expect(instances.Crashed()).To(BeFalse())
+		})
+	})
+
+	Describe("V3Parser", func() {
+		jsonBody := `{
+   "resources": [
+      {
+         "type": "web",
+         "index": 0,
+         "state": "RUNNING",
+         "uptime": 9042,
+         "host": "10.0.0.1"
+      },
+      {
+         "type": "web",
+         "index": 1,
+         "state": "CRASHED",
+         "uptime": 0,
+         "host": "10.0.0.2"
+      }
+   ]
+}`
+
+		It("parses the process stats into instances by index", func() {
+			instances, err := V3InstancesParser{}.Parse([]byte(jsonBody))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(instances).To(HaveLen(2))
+			Expect(instances["0"].State).To(Equal(InstanceRunning))
+			Expect(instances["0"].Uptime).To(Equal(int64(9042)))
+			Expect(instances["1"].State).To(Equal(InstanceCrashed))
+		})
+	})
+
+	Describe("Indexes", func() {
+		It("returns the instance indexes in numerical order", func() {
+			instances := Instances{
+				"10": {State: InstanceRunning},
+				"2":  {State: InstanceRunning},
+				"0":  {State: InstanceRunning},
+			}
+			Expect(instances.Indexes()).To(Equal([]string{"0", "2", "10"}))
+		})
+	})
+
+	Describe("Replaces", func() {
+		It("compares the start time of v2 instances", func() {
+			previous := Instance{State: InstanceRunning, Since: 1458233226}
+			Expect(Instance{State: InstanceRunning, Since: 1458233300}.Replaces(previous)).To(BeTrue())
+			Expect(Instance{State: InstanceRunning, Since: 1458233226}.Replaces(previous)).To(BeFalse())
+		})
+
+		It("compares the uptime of v3 instances", func() {
+			previous := Instance{State: InstanceRunning, Uptime: 9042}
+			Expect(Instance{State: InstanceRunning, Uptime: 3}.Replaces(previous)).To(BeTrue())
+			Expect(Instance{State: InstanceRunning, Uptime: 9047}.Replaces(previous)).To(BeFalse())
+		})
+	})
+})
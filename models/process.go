@@ -0,0 +1,50 @@
+package models
+
+import "encoding/json"
+
+type Processes []Process
+
+// Process is a v3 process of an app. The web process holds the memory that
+// v2 reports on the app.
+type Process struct {
+	AppGuid string
+	Type    string
+	Memory  int
+}
+
+type v3ProcessesResponse struct {
+	Resources []struct {
+		Type          string `json:"type"`
+		MemoryInMB    int    `json:"memory_in_mb"`
+		Relationships struct {
+			App struct {
+				Data struct {
+					Guid string `json:"guid"`
+				} `json:"data"`
+			} `json:"app"`
+		} `json:"relationships"`
+	} `json:"resources"`
+}
+
+type V3ProcessesParser struct{}
+
+func (p V3ProcessesParser) Parse(body []byte) (Processes, error) {
+	var response v3ProcessesResponse
+	var emptyProcesses Processes
+
+	err := json.Unmarshal(body, &response)
+	if err != nil {
+		return emptyProcesses, err
+	}
+
+	var processes Processes
+	for _, resource := range response.Resources {
+		processes = append(processes, Process{
+			AppGuid: resource.Relationships.App.Data.Guid,
+			Type:    resource.Type,
+			Memory:  resource.MemoryInMB,
+		})
+	}
+
+	return processes, nil
+}
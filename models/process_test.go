@@ -0,0 +1,42 @@
+package models_test
+
+import (
+	. "github.com/cloudfoundry-incubator/app-restarter/models"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Process", func() {
+	Describe("V3Parser", func() {
+		It("parses the app and memory of the processes", func() {
+			processes, err := V3ProcessesParser{}.Parse([]byte(`{
+   "pagination": {
+      "total_results": 1,
+      "total_pages": 1,
+      "next": null
+   },
+   "resources": [
+      {
+         "guid": "6a901b7c-9417-4dc1-8189-d3234aa0ab82",
+         "type": "web",
+         "instances": 2,
+         "memory_in_mb": 256,
+         "disk_in_mb": 1024,
+         "relationships": {
+            "app": {
+               "data": {
+                  "guid": "b2ba6466-23f7-4f90-935b-4da1c87b8943"
+               }
+            }
+         }
+      }
+   ]
+}`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(processes).To(Equal(Processes{
+				{AppGuid: "b2ba6466-23f7-4f90-935b-4da1c87b8943", Type: "web", Memory: 256},
+			}))
+		})
+	})
+})
@@ -49,3 +49,65 @@ func (a SpacesParser) Parse(body []byte) (Spaces, error) {
 
 	return response.Resources, nil
 }
+
+type v3SpacesResponse struct {
+	Resources []v3Space `json:"resources"`
+	Included  struct {
+		Organizations []v3Organization `json:"organizations"`
+	} `json:"included"`
+}
+
+type v3Space struct {
+	Guid          string `json:"guid"`
+	Name          string `json:"name"`
+	Relationships struct {
+		Organization struct {
+			Data struct {
+				Guid string `json:"guid"`
+			} `json:"data"`
+		} `json:"organization"`
+	} `json:"relationships"`
+}
+
+type v3Organization struct {
+	Guid string `json:"guid"`
+	Name string `json:"name"`
+}
+
+type V3SpacesParser struct{}
+
+func (a V3SpacesParser) Parse(body []byte) (Spaces, error) {
+	var response v3SpacesResponse
+	var emptySpaces Spaces
+
+	err := json.Unmarshal(body, &response)
+	if err != nil {
+		return emptySpaces, err
+	}
+
+	organizations := make(map[string]string)
+	for _, org := range response.Included.Organizations {
+		organizations[org.Guid] = org.Name
+	}
+
+	var spaces Spaces
+	for _, resource := range response.Resources {
+		orgGuid := resource.Relationships.Organization.Data.Guid
+
+		spaces = append(spaces, Space{
+			SpaceEntity: SpaceEntity{
+				Name:             resource.Name,
+				OrganizationGuid: orgGuid,
+				Organization: Organization{
+					OrganizationEntity:   OrganizationEntity{Name: organizations[orgGuid]},
+					OrganizationMetadata: OrganizationMetadata{Guid: orgGuid},
+				},
+			},
+			SpaceMetadata: SpaceMetadata{
+				Guid: resource.Guid,
+			},
+		})
+	}
+
+	return spaces, nil
+}
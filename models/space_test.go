@@ -366,4 +366,65 @@ var _ = Describe("Space", func() {
 			Expect(org.Guid).To(Equal("94fe9c1a-6bda-483b-bf48-d6fa39d08cb6"))
 		})
 	})
+
+	Describe("V3Parser", func() {
+		jsonBody := `{
+  "pagination": {
+    "total_results": 1,
+    "total_pages": 1,
+    "first": { "href": "https://api.example.org/v3/spaces?include=organization&page=1&per_page=50" },
+    "last": { "href": "https://api.example.org/v3/spaces?include=organization&page=1&per_page=50" },
+    "next": null,
+    "previous": null
+  },
+  "resources": [
+    {
+      "guid": "1f7ac3a5-6f4e-4d6c-8edd-ce694fc8c907",
+      "created_at": "2016-03-16T16:36:38Z",
+      "updated_at": "2016-03-16T16:36:38Z",
+      "name": "myspace",
+      "relationships": {
+        "organization": {
+          "data": {
+            "guid": "94fe9c1a-6bda-483b-bf48-d6fa39d08cb6"
+          }
+        },
+        "quota": {
+          "data": null
+        }
+      },
+      "links": {
+        "self": { "href": "https://api.example.org/v3/spaces/1f7ac3a5-6f4e-4d6c-8edd-ce694fc8c907" },
+        "organization": { "href": "https://api.example.org/v3/organizations/94fe9c1a-6bda-483b-bf48-d6fa39d08cb6" }
+      }
+    }
+  ],
+  "included": {
+    "organizations": [
+      {
+        "guid": "94fe9c1a-6bda-483b-bf48-d6fa39d08cb6",
+        "created_at": "2016-03-16T16:36:24Z",
+        "updated_at": "2016-03-17T22:08:00Z",
+        "name": "myorg",
+        "suspended": false
+      }
+    ]
+  }
+}`
+
+		It("parses", func() {
+			spaces, err := V3SpacesParser{}.Parse([]byte(jsonBody))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(spaces).To(HaveLen(1))
+
+			space := spaces[0]
+			Expect(space.Name).To(Equal("myspace"))
+			Expect(space.Guid).To(Equal("1f7ac3a5-6f4e-4d6c-8edd-ce694fc8c907"))
+			Expect(space.OrganizationGuid).To(Equal("94fe9c1a-6bda-483b-bf48-d6fa39d08cb6"))
+
+			org := space.Organization
+			Expect(org.Name).To(Equal("myorg"))
+			Expect(org.Guid).To(Equal("94fe9c1a-6bda-483b-bf48-d6fa39d08cb6"))
+		})
+	})
 })
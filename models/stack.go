@@ -0,0 +1,65 @@
+package models
+
+import "encoding/json"
+
+type Stacks []Stack
+
+type StackEntity struct {
+	Name string `json:"name"`
+}
+
+type StackMetadata struct {
+	Guid string `json:"guid"`
+}
+
+type StacksResponse struct {
+	Resources Stacks `json:"resources"`
+}
+
+type Stack struct {
+	StackEntity   `json:"entity"`
+	StackMetadata `json:"metadata"`
+}
+
+type v3StacksResponse struct {
+	Resources []struct {
+		Guid string `json:"guid"`
+		Name string `json:"name"`
+	} `json:"resources"`
+}
+
+type StacksParser struct{}
+
+func (a StacksParser) Parse(body []byte) (Stacks, error) {
+	var response StacksResponse
+	var emptyStacks Stacks
+
+	err := json.Unmarshal(body, &response)
+	if err != nil {
+		return emptyStacks, err
+	}
+
+	return response.Resources, nil
+}
+
+type V3StacksParser struct{}
+
+func (a V3StacksParser) Parse(body []byte) (Stacks, error) {
+	var response v3StacksResponse
+	var emptyStacks Stacks
+
+	err := json.Unmarshal(body, &response)
+	if err != nil {
+		return emptyStacks, err
+	}
+
+	var stacks Stacks
+	for _, resource := range response.Resources {
+		stacks = append(stacks, Stack{
+			StackEntity:   StackEntity{Name: resource.Name},
+			StackMetadata: StackMetadata{Guid: resource.Guid},
+		})
+	}
+
+	return stacks, nil
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+const (
+	StagingPending = "PENDING"
+	StagingStaged  = "STAGED"
+	StagingFailed  = "FAILED"
+)
+
+// Staging is the state of staging an app, read from the app on v2 and from
+// the build on v3.
+type Staging struct {
+	State       string
+	Reason      string
+	DropletGuid string
+}
+
+type v2StagingResponse struct {
+	Entity struct {
+		PackageState             string `json:"package_state"`
+		StagingFailedReason      string `json:"staging_failed_reason"`
+		StagingFailedDescription string `json:"staging_failed_description"`
+	} `json:"entity"`
+}
+
+type StagingParser struct{}
+
+func (p StagingParser) Parse(body []byte) (Staging, error) {
+	var response v2StagingResponse
+	var emptyStaging Staging
+
+	err := json.Unmarshal(body, &response)
+	if err != nil {
+		return emptyStaging, err
+	}
+
+	staging := Staging{State: response.Entity.PackageState}
+	if staging.State == StagingFailed {
+		staging.Reason = strings.TrimSpace(response.Entity.StagingFailedReason + " " + response.Entity.StagingFailedDescription)
+	}
+
+	return staging, nil
+}
+
+type v3BuildResponse struct {
+	State   string `json:"state"`
+	Error   string `json:"error"`
+	Droplet *struct {
+		Guid string `json:"guid"`
+	} `json:"droplet"`
+}
+
+type V3StagingParser struct{}
+
+func (p V3StagingParser) Parse(body []byte) (Staging, error) {
+	var response v3BuildResponse
+	var emptyStaging Staging
+
+	err := json.Unmarshal(body, &response)
+	if err != nil {
+		return emptyStaging, err
+	}
+
+	staging := Staging{State: StagingPending, Reason: response.Error}
+	switch response.State {
+	case StagingStaged:
+		staging.State = StagingStaged
+	case StagingFailed:
+		staging.State = StagingFailed
+	}
+
+	if response.Droplet != nil {
+		staging.DropletGuid = response.Droplet.Guid
+	}
+
+	return staging, nil
+}
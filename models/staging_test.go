@@ -0,0 +1,80 @@
+package models_test
+
+import (
+	. "github.com/cloudfoundry-incubator/app-restarter/models"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Staging", func() {
+	Describe("Parser", func() {
+		It("parses the package state of the app", func() {
+			staging, err := StagingParser{}.Parse([]byte(`{
+   "metadata": {
+      "guid": "b2ba6466-23f7-4f90-935b-4da1c87b8943"
+   },
+   "entity": {
+      "name": "ilovedogs",
+      "package_state": "STAGED",
+      "staging_failed_reason": null,
+      "staging_failed_description": null
+   }
+}`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(staging.State).To(Equal(StagingStaged))
+			Expect(staging.Reason).To(BeEmpty())
+		})
+
+		It("parses the reason staging failed", func() {
+			staging, err := StagingParser{}.Parse([]byte(`{
+   "entity": {
+      "package_state": "FAILED",
+      "staging_failed_reason": "BuildpackCompileFailed",
+      "staging_failed_description": "App staging failed in the buildpack compile phase"
+   }
+}`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(staging.State).To(Equal(StagingFailed))
+			Expect(staging.Reason).To(Equal("BuildpackCompileFailed App staging failed in the buildpack compile phase"))
+		})
+	})
+
+	Describe("V3Parser", func() {
+		It("parses a build that is staging", func() {
+			staging, err := V3StagingParser{}.Parse([]byte(`{
+   "guid": "585bc3c1-3743-497d-88b0-403ad6b56d16",
+   "state": "STAGING",
+   "error": null,
+   "droplet": null
+}`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(staging.State).To(Equal(StagingPending))
+			Expect(staging.DropletGuid).To(BeEmpty())
+		})
+
+		It("parses the droplet of a staged build", func() {
+			staging, err := V3StagingParser{}.Parse([]byte(`{
+   "guid": "585bc3c1-3743-497d-88b0-403ad6b56d16",
+   "state": "STAGED",
+   "error": null,
+   "droplet": {
+      "guid": "1e1186e7-d803-4c46-b9d6-5c81e50fe55a"
+   }
+}`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(staging.State).To(Equal(StagingStaged))
+			Expect(staging.DropletGuid).To(Equal("1e1186e7-d803-4c46-b9d6-5c81e50fe55a"))
+		})
+
+		It("parses the error of a failed build", func() {
+			staging, err := V3StagingParser{}.Parse([]byte(`{
+   "state": "FAILED",
+   "error": "StagingError - Buildpack compilation step failed"
+}`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(staging.State).To(Equal(StagingFailed))
+			Expect(staging.Reason).To(Equal("StagingError - Buildpack compilation step failed"))
+		})
+	})
+})
@@ -2,19 +2,41 @@ package resource_mapper
 
 import (
 	"fmt"
+	"strings"
+
 	"github.com/cloudfoundry-incubator/app-restarter/api"
 	"github.com/cloudfoundry-incubator/app-restarter/models"
 )
 
-type AppsGetterFunc func(ApplicationsParser, PaginatedRequester) (models.Applications, error)
+type AppsGetterFunc func(ApplicationsParser, PaginatedRequester, Lookups) (models.Applications, error)
+
+// Lookups fetch single resources, for what the listing of apps cannot be
+// filtered by or does not include.
+type Lookups struct {
+	// App fetches an app by guid. It is only given on v2, which cannot
+	// filter the apps by guid.
+	App func(guid string) (models.Application, error)
+
+	// Stacks fetches the stacks with the name.
+	Stacks func(name string) (models.Stacks, error)
+
+	// Droplet fetches the current droplet of an app. It is only given on
+	// v3, where an app only names the buildpacks it was pushed with.
+	Droplet func(appGuid string) (models.Droplet, error)
+}
 
 type ApplicationsParser interface {
 	Parse([]byte) (models.Applications, error)
 }
 
+// AppsGetter fetches the apps of the orgs or spaces in scope, filtering by
+// the orgs when only orgs are given. The guids are sent in batches, so that
+// the query string stays short even for orgs with many spaces.
 type AppsGetter struct {
-	OrganizationGuid string
-	SpaceGuid        string
+	Scoped     bool
+	OrgGuids   []string
+	SpaceGuids []string
+	Filter     AppsFilter
 }
 
 type OrgNotFoundErr struct {
@@ -33,25 +55,59 @@ func (e SpaceNotFoundErr) Error() string {
 	return fmt.Sprintf("Space not found: %s", e.SpaceName)
 }
 
+type InvalidSpaceErr struct {
+	SpaceName string
+}
+
+func (e InvalidSpaceErr) Error() string {
+	return fmt.Sprintf("Invalid space, expected SPACE or ORG/SPACE: %s", e.SpaceName)
+}
+
+// NewAppsGetterFunc resolves the given orgs and spaces into the guids of the
+// orgs or, as soon as any spaces are given, of the spaces in scope. Spaces
+// are either named in the targeted org or given as ORG/SPACE.
 func NewAppsGetterFunc(
 	cliConnection api.Connection,
-	orgName string,
-	spaceName string,
+	orgNames []string,
+	spaceNames []string,
+	appsFilter AppsFilter,
 ) (AppsGetterFunc, error) {
-	command := AppsGetter{}
+	command := AppsGetter{
+		Scoped: len(orgNames) > 0 || len(spaceNames) > 0,
+		Filter: appsFilter,
+	}
+
+	seen := map[string]bool{}
+	addSpace := func(guid string) {
+		if !seen[guid] {
+			seen[guid] = true
+			command.SpaceGuids = append(command.SpaceGuids, guid)
+		}
+	}
 
-	if orgName != "" {
+	for _, orgName := range orgNames {
 		org, err := cliConnection.GetOrg(orgName)
 		if err != nil || org.Guid == "" {
 			return nil, OrgNotFoundErr{OrganizationName: orgName}
 		}
-		command.OrganizationGuid = org.Guid
-	} else if spaceName != "" {
-		space, err := cliConnection.GetSpace(spaceName)
-		if err != nil || space.Guid == "" {
-			return nil, SpaceNotFoundErr{SpaceName: spaceName}
+
+		if len(spaceNames) == 0 {
+			command.OrgGuids = append(command.OrgGuids, org.Guid)
+			continue
+		}
+
+		for _, space := range org.Spaces {
+			addSpace(space.Guid)
 		}
-		command.SpaceGuid = space.Guid
+	}
+
+	for _, spaceName := range spaceNames {
+		guid, err := resolveSpace(cliConnection, spaceName)
+		if err != nil {
+			return nil, err
+		}
+
+		addSpace(guid)
 	}
 
 	var appsGetterFunc = command.Apps
@@ -59,43 +115,100 @@ func NewAppsGetterFunc(
 	return appsGetterFunc, nil
 }
 
+func resolveSpace(cliConnection api.Connection, spaceName string) (string, error) {
+	parts := strings.Split(spaceName, "/")
+
+	switch {
+	case len(parts) == 1 && parts[0] != "":
+		space, err := cliConnection.GetSpace(spaceName)
+		if err != nil || space.Guid == "" {
+			return "", SpaceNotFoundErr{SpaceName: spaceName}
+		}
+
+		return space.Guid, nil
+	case len(parts) == 2 && parts[0] != "" && parts[1] != "":
+		org, err := cliConnection.GetOrg(parts[0])
+		if err != nil || org.Guid == "" {
+			return "", OrgNotFoundErr{OrganizationName: parts[0]}
+		}
+
+		for _, space := range org.Spaces {
+			if space.Name == parts[1] {
+				return space.Guid, nil
+			}
+		}
+
+		return "", SpaceNotFoundErr{SpaceName: spaceName}
+	default:
+		return "", InvalidSpaceErr{SpaceName: spaceName}
+	}
+}
+
 func (c AppsGetter) Apps(
 	appsParser ApplicationsParser,
 	paginatedRequester PaginatedRequester,
+	lookups Lookups,
 ) (models.Applications, error) {
 	var noApps models.Applications
 
-	filter := api.Filters{}
-
-	if c.OrganizationGuid != "" {
-		filter = append(
-			filter,
-			api.EqualFilter{
-				Name:  "organization_guid",
-				Value: c.OrganizationGuid,
-			},
-		)
-	} else if c.SpaceGuid != "" {
-		filter = append(
-			filter,
-			api.EqualFilter{
-				Name:  "space_guid",
-				Value: c.SpaceGuid,
-			},
-		)
+	filters := []api.Filter{api.Filters{}}
+
+	if c.Scoped {
+		name, guids := "space_guid", c.SpaceGuids
+		if len(c.OrgGuids) > 0 {
+			name, guids = "organization_guid", c.OrgGuids
+		}
+
+		filters = nil
+		for len(guids) > 0 {
+			batch := guids[:batchSize(len(guids))]
+			guids = guids[len(batch):]
+
+			var values []interface{}
+			for _, guid := range batch {
+				values = append(values, guid)
+			}
+
+			filters = append(filters, api.InclusionFilter{Name: name, Values: values})
+		}
 	}
 
-	params := map[string]interface{}{}
+	var applications models.Applications
+
+	for _, filter := range filters {
+		apps, err := c.fetch(appsParser, paginatedRequester, filter, lookups)
+		if err != nil {
+			return noApps, err
+		}
 
-	responseBodies, err := paginatedRequester.Do(filter, params)
-	if err != nil {
-		return noApps, err
+		applications = append(applications, apps...)
 	}
 
+	return applications, nil
+}
+
+func (c AppsGetter) fetch(
+	appsParser ApplicationsParser,
+	paginatedRequester PaginatedRequester,
+	filter api.Filter,
+	lookups Lookups,
+) (models.Applications, error) {
+	var noApps models.Applications
+
+	params := map[string]interface{}{}
+
+	pages := paginatedRequester.Pages(filter, params)
+	defer pages.Close()
+
 	var applications models.Applications
 
-	for _, nextBody := range responseBodies {
-		apps, err := appsParser.Parse(nextBody)
+	for pages.Next() {
+		apps, err := appsParser.Parse(pages.Body())
+		if err != nil {
+			return noApps, err
+		}
+
+		apps, err = c.Filter.Apply(apps, lookups)
 		if err != nil {
 			return noApps, err
 		}
@@ -103,5 +216,9 @@ func (c AppsGetter) Apps(
 		applications = append(applications, apps...)
 	}
 
+	if err := pages.Err(); err != nil {
+		return noApps, ListErr{Resource: "apps", Err: err}
+	}
+
 	return applications, nil
 }
@@ -0,0 +1,228 @@
+package resource_mapper
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/cloudfoundry-incubator/app-restarter/api"
+	"github.com/cloudfoundry-incubator/app-restarter/models"
+)
+
+// AppsFilter narrows down the apps returned by the Cloud Controller to the
+// ones matching by name, buildpack and stack and not living in an excluded
+// org or space.
+type AppsFilter struct {
+	NamePatterns       []*regexp.Regexp
+	ExcludePatterns    []*regexp.Regexp
+	ExcludedSpaceGuids map[string]bool
+
+	Buildpacks []string
+	StackGuids map[string]bool
+	StackNames map[string]bool
+}
+
+type StackNotFoundErr struct {
+	StackName string
+}
+
+func (e StackNotFoundErr) Error() string {
+	return fmt.Sprintf("Stack not found: %s", e.StackName)
+}
+
+type InvalidPatternErr struct {
+	Pattern string
+	Err     error
+}
+
+func (e InvalidPatternErr) Error() string {
+	return fmt.Sprintf("Invalid app pattern %s: %s", e.Pattern, e.Err)
+}
+
+func NewAppsFilter(
+	cliConnection api.Connection,
+	appPatterns []string,
+	excludePatterns []string,
+	excludeOrgNames []string,
+	excludeSpaceNames []string,
+	buildpacks []string,
+	stackNames []string,
+) (AppsFilter, error) {
+	filter := AppsFilter{
+		ExcludedSpaceGuids: map[string]bool{},
+		Buildpacks:         buildpacks,
+		StackGuids:         map[string]bool{},
+		StackNames:         map[string]bool{},
+	}
+
+	var err error
+
+	filter.NamePatterns, err = compilePatterns(appPatterns)
+	if err != nil {
+		return AppsFilter{}, err
+	}
+
+	filter.ExcludePatterns, err = compilePatterns(excludePatterns)
+	if err != nil {
+		return AppsFilter{}, err
+	}
+
+	for _, orgName := range excludeOrgNames {
+		org, err := cliConnection.GetOrg(orgName)
+		if err != nil || org.Guid == "" {
+			return AppsFilter{}, OrgNotFoundErr{OrganizationName: orgName}
+		}
+
+		for _, space := range org.Spaces {
+			filter.ExcludedSpaceGuids[space.Guid] = true
+		}
+	}
+
+	for _, spaceName := range excludeSpaceNames {
+		guid, err := resolveSpace(cliConnection, spaceName)
+		if err != nil {
+			return AppsFilter{}, err
+		}
+
+		filter.ExcludedSpaceGuids[guid] = true
+	}
+
+	for _, stackName := range stackNames {
+		filter.StackNames[stackName] = true
+	}
+
+	return filter, nil
+}
+
+// resolveStacks looks up the guids of the stacks, which v2 apps refer to
+// their stack by, once the API the apps are fetched from is known.
+func (f AppsFilter) resolveStacks(lookups Lookups) error {
+	if len(f.StackNames) == 0 || len(f.StackGuids) > 0 || lookups.Stacks == nil {
+		return nil
+	}
+
+	for stackName := range f.StackNames {
+		stacks, err := lookups.Stacks(stackName)
+		if err != nil {
+			return ListErr{Resource: "stacks", Err: err}
+		}
+
+		found := false
+		for _, stack := range stacks {
+			if stack.Name == stackName {
+				f.StackGuids[stack.Guid] = true
+				found = true
+			}
+		}
+
+		if !found {
+			return StackNotFoundErr{StackName: stackName}
+		}
+	}
+
+	return nil
+}
+
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	var compiled []*regexp.Regexp
+
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, InvalidPatternErr{Pattern: pattern, Err: err}
+		}
+
+		compiled = append(compiled, re)
+	}
+
+	return compiled, nil
+}
+
+func (f AppsFilter) Matches(app models.Application) bool {
+	if f.ExcludedSpaceGuids[app.SpaceGuid] {
+		return false
+	}
+
+	if len(f.Buildpacks) > 0 && !f.matchesBuildpack(app) {
+		return false
+	}
+
+	if len(f.StackNames) > 0 && !f.StackGuids[app.StackGuid] && !f.StackNames[app.StackName] {
+		return false
+	}
+
+	for _, re := range f.ExcludePatterns {
+		if re.MatchString(app.Name) {
+			return false
+		}
+	}
+
+	if len(f.NamePatterns) == 0 {
+		return true
+	}
+
+	for _, re := range f.NamePatterns {
+		if re.MatchString(app.Name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchesBuildpack reports whether the app is pushed with one of the
+// buildpacks or was detected to need it. Detected buildpacks usually carry a
+// version after the name, e.g. "staticfile 1.3.1".
+func (f AppsFilter) matchesBuildpack(app models.Application) bool {
+	for _, buildpack := range f.Buildpacks {
+		if app.Buildpack == buildpack || app.DetectedBuildpack == buildpack {
+			return true
+		}
+
+		if strings.HasPrefix(app.DetectedBuildpack, buildpack+" ") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Apply returns the matching apps. The buildpack of v3 apps is taken from
+// their current droplet, but only for apps that match otherwise.
+func (f AppsFilter) Apply(apps models.Applications, lookups Lookups) (models.Applications, error) {
+	var noApps models.Applications
+
+	err := f.resolveStacks(lookups)
+	if err != nil {
+		return noApps, err
+	}
+
+	withoutBuildpacks := f
+	withoutBuildpacks.Buildpacks = nil
+
+	var matching models.Applications
+
+	for _, app := range apps {
+		if len(f.Buildpacks) > 0 && lookups.Droplet != nil {
+			if !withoutBuildpacks.Matches(app) {
+				continue
+			}
+
+			droplet, err := lookups.Droplet(app.Guid)
+			if err != nil {
+				return noApps, ListErr{Resource: "droplets", Err: err}
+			}
+
+			if app.Buildpack == "" {
+				app.Buildpack = droplet.Buildpack
+			}
+			app.DetectedBuildpack = droplet.DetectedBuildpack
+		}
+
+		if f.Matches(app) {
+			matching = append(matching, app)
+		}
+	}
+
+	return matching, nil
+}
@@ -0,0 +1,196 @@
+package resource_mapper_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry-incubator/app-restarter/api/apifakes"
+	"github.com/cloudfoundry-incubator/app-restarter/models"
+	. "github.com/cloudfoundry-incubator/app-restarter/resource_mapper"
+	"github.com/cloudfoundry/cli/plugin/models"
+)
+
+func app(name string, spaceGuid string) models.Application {
+	var a models.Application
+	a.Name = name
+	a.Guid = name + "-guid"
+	a.SpaceGuid = spaceGuid
+	return a
+}
+
+func names(apps models.Applications) []string {
+	var result []string
+	for _, a := range apps {
+		result = append(result, a.Name)
+	}
+	return result
+}
+
+var _ = Describe("AppsFilter", func() {
+	var (
+		cliConnection *apifakes.FakeConnection
+
+		appPatterns       []string
+		excludePatterns   []string
+		excludeOrgNames   []string
+		excludeSpaceNames []string
+
+		filter AppsFilter
+		err    error
+
+		apps models.Applications
+	)
+
+	BeforeEach(func() {
+		cliConnection = new(apifakes.FakeConnection)
+
+		appPatterns = nil
+		excludePatterns = nil
+		excludeOrgNames = nil
+		excludeSpaceNames = nil
+
+		apps = models.Applications{
+			app("frontend", "space-a-guid"),
+			app("frontend-worker", "space-a-guid"),
+			app("backend", "space-b-guid"),
+			app("backend-staging", "space-c-guid"),
+		}
+	})
+
+	JustBeforeEach(func() {
+		filter, err = NewAppsFilter(cliConnection, appPatterns, excludePatterns, excludeOrgNames, excludeSpaceNames, nil, nil)
+	})
+
+	Context("without patterns or exclusions", func() {
+		It("matches all apps", func() {
+			Expect(err).NotTo(HaveOccurred())
+
+			matching, err := filter.Apply(apps, Lookups{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(names(matching)).To(Equal([]string{"frontend", "frontend-worker", "backend", "backend-staging"}))
+		})
+	})
+
+	Context("with app patterns", func() {
+		BeforeEach(func() {
+			appPatterns = []string{"^frontend$", "^back"}
+		})
+
+		It("matches the apps matching any of the patterns", func() {
+			Expect(err).NotTo(HaveOccurred())
+
+			matching, err := filter.Apply(apps, Lookups{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(names(matching)).To(Equal([]string{"frontend", "backend", "backend-staging"}))
+		})
+	})
+
+	Context("with exclude patterns", func() {
+		BeforeEach(func() {
+			appPatterns = []string{"^back"}
+			excludePatterns = []string{"-worker$", "-staging$"}
+		})
+
+		It("leaves out the apps matching any of them, even if they match an app pattern", func() {
+			Expect(err).NotTo(HaveOccurred())
+
+			matching, err := filter.Apply(apps, Lookups{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(names(matching)).To(Equal([]string{"backend"}))
+		})
+	})
+
+	Context("with an invalid pattern", func() {
+		BeforeEach(func() {
+			excludePatterns = []string{"("}
+		})
+
+		It("returns an InvalidPatternErr", func() {
+			Expect(err).To(HaveOccurred())
+			Expect(err).To(BeAssignableToTypeOf(InvalidPatternErr{}))
+			Expect(err.(InvalidPatternErr).Pattern).To(Equal("("))
+		})
+	})
+
+	Context("with excluded orgs", func() {
+		BeforeEach(func() {
+			excludeOrgNames = []string{"org-a"}
+			cliConnection.GetOrgReturns(plugin_models.GetOrg_Model{
+				Guid: "org-a-guid",
+				Spaces: []plugin_models.GetOrg_Space{
+					{Guid: "space-a-guid", Name: "space-a"},
+					{Guid: "space-b-guid", Name: "space-b"},
+				},
+			}, nil)
+		})
+
+		It("leaves out the apps in the spaces of the org", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cliConnection.GetOrgArgsForCall(0)).To(Equal("org-a"))
+
+			matching, err := filter.Apply(apps, Lookups{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(names(matching)).To(Equal([]string{"backend-staging"}))
+		})
+
+		Context("when the org does not exist", func() {
+			BeforeEach(func() {
+				cliConnection.GetOrgReturns(plugin_models.GetOrg_Model{}, errors.New("not found"))
+			})
+
+			It("returns an OrgNotFoundErr", func() {
+				Expect(err).To(Equal(OrgNotFoundErr{OrganizationName: "org-a"}))
+			})
+		})
+	})
+
+	Context("with excluded spaces", func() {
+		BeforeEach(func() {
+			excludeSpaceNames = []string{"space-c"}
+			cliConnection.GetSpaceReturns(plugin_models.GetSpace_Model{
+				GetSpaces_Model: plugin_models.GetSpaces_Model{Guid: "space-c-guid", Name: "space-c"},
+			}, nil)
+		})
+
+		It("leaves out the apps in the space", func() {
+			Expect(err).NotTo(HaveOccurred())
+
+			matching, err := filter.Apply(apps, Lookups{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(names(matching)).To(Equal([]string{"frontend", "frontend-worker", "backend"}))
+		})
+
+		Context("when the space is given as ORG/SPACE", func() {
+			BeforeEach(func() {
+				excludeSpaceNames = []string{"org-b/space-b"}
+				cliConnection.GetOrgReturns(plugin_models.GetOrg_Model{
+					Guid: "org-b-guid",
+					Spaces: []plugin_models.GetOrg_Space{
+						{Guid: "space-b-guid", Name: "space-b"},
+					},
+				}, nil)
+			})
+
+			It("leaves out the apps in the space of that org", func() {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(cliConnection.GetSpaceCallCount()).To(Equal(0))
+
+				matching, err := filter.Apply(apps, Lookups{})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(names(matching)).To(Equal([]string{"frontend", "frontend-worker", "backend-staging"}))
+			})
+		})
+
+		Context("when the space is invalid", func() {
+			BeforeEach(func() {
+				excludeSpaceNames = []string{"org/"}
+			})
+
+			It("returns an InvalidSpaceErr", func() {
+				Expect(err).To(Equal(InvalidSpaceErr{SpaceName: "org/"}))
+			})
+		})
+	})
+})
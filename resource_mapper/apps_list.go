@@ -0,0 +1,258 @@
+package resource_mapper
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/cloudfoundry-incubator/app-restarter/api"
+	"github.com/cloudfoundry-incubator/app-restarter/models"
+)
+
+// appsListBatchSize bounds the number of values per inclusion filter, which
+// keeps the query string of each request reasonably short.
+const appsListBatchSize = 50
+
+type appsListEntry struct {
+	Entry     string
+	Guid      string
+	SpaceGuid string
+	Name      string
+}
+
+// AppsListGetter fetches an explicit list of apps, each given either by guid
+// or as ORG/SPACE/APP. Names are looked up in batches with an inclusion
+// filter, and so are guids on v3. v2 cannot filter apps by guid, so there
+// each app is fetched on its own.
+type AppsListGetter struct {
+	Entries []appsListEntry
+	Filter  AppsFilter
+}
+
+type UnresolvedAppsErr struct {
+	Entries []string
+}
+
+func (e UnresolvedAppsErr) Error() string {
+	return fmt.Sprintf("Apps not found: %s", strings.Join(e.Entries, ", "))
+}
+
+type AppNameWithCommaErr struct {
+	Entry string
+}
+
+func (e AppNameWithCommaErr) Error() string {
+	return fmt.Sprintf("App names containing a comma are not supported, use the app GUID instead: %s", e.Entry)
+}
+
+type InvalidAppErr struct {
+	Entry string
+}
+
+func (e InvalidAppErr) Error() string {
+	return fmt.Sprintf("Invalid app, expected GUID or ORG/SPACE/APP: %s", e.Entry)
+}
+
+func NewAppsListGetterFunc(
+	cliConnection api.Connection,
+	lines []string,
+	appsFilter AppsFilter,
+) (AppsGetterFunc, error) {
+	command := AppsListGetter{
+		Filter: appsFilter,
+	}
+
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.Split(line, "/")
+		switch len(parts) {
+		case 1:
+			command.Entries = append(command.Entries, appsListEntry{Entry: line, Guid: line})
+		case 3:
+			if parts[2] == "" {
+				return nil, InvalidAppErr{Entry: line}
+			}
+
+			// The inclusion filter separates names with commas.
+			if strings.Contains(parts[2], ",") {
+				return nil, AppNameWithCommaErr{Entry: line}
+			}
+
+			spaceGuid, err := resolveSpace(cliConnection, parts[0]+"/"+parts[1])
+			if err != nil {
+				return nil, err
+			}
+
+			command.Entries = append(command.Entries, appsListEntry{Entry: line, SpaceGuid: spaceGuid, Name: parts[2]})
+		default:
+			return nil, InvalidAppErr{Entry: line}
+		}
+	}
+
+	var appsGetterFunc = command.Apps
+
+	return appsGetterFunc, nil
+}
+
+func (c AppsListGetter) Apps(
+	appsParser ApplicationsParser,
+	paginatedRequester PaginatedRequester,
+	lookups Lookups,
+) (models.Applications, error) {
+	var noApps models.Applications
+
+	var guids []interface{}
+	var namedEntries []appsListEntry
+	for _, entry := range c.Entries {
+		if entry.Guid != "" {
+			guids = append(guids, entry.Guid)
+		} else {
+			namedEntries = append(namedEntries, entry)
+		}
+	}
+
+	byGuid := map[string]models.Application{}
+	byName := map[string]models.Application{}
+
+	if lookups.App != nil {
+		for _, entry := range c.Entries {
+			if entry.Guid == "" {
+				continue
+			}
+			if _, ok := byGuid[entry.Guid]; ok {
+				continue
+			}
+
+			app, err := lookups.App(entry.Guid)
+			if err != nil {
+				if ccErr, ok := err.(api.CloudControllerError); ok && ccErr.StatusCode == http.StatusNotFound {
+					continue
+				}
+				return noApps, ListErr{Resource: "apps", Err: err}
+			}
+
+			byGuid[app.Guid] = app
+		}
+		guids = nil
+	}
+
+	for len(guids) > 0 {
+		batch := guids[:batchSize(len(guids))]
+		guids = guids[len(batch):]
+
+		apps, err := c.fetch(appsParser, paginatedRequester, api.InclusionFilter{Name: "guid", Values: batch})
+		if err != nil {
+			return noApps, err
+		}
+
+		for _, app := range apps {
+			byGuid[app.Guid] = app
+		}
+	}
+
+	for len(namedEntries) > 0 {
+		batch := namedEntries[:batchSize(len(namedEntries))]
+		namedEntries = namedEntries[len(batch):]
+
+		filter := api.Filters{
+			api.InclusionFilter{Name: "name", Values: uniqueValues(batch, func(e appsListEntry) string { return e.Name })},
+			api.InclusionFilter{Name: "space_guid", Values: uniqueValues(batch, func(e appsListEntry) string { return e.SpaceGuid })},
+		}
+
+		apps, err := c.fetch(appsParser, paginatedRequester, filter)
+		if err != nil {
+			return noApps, err
+		}
+
+		for _, app := range apps {
+			byName[app.SpaceGuid+"/"+app.Name] = app
+		}
+	}
+
+	var applications models.Applications
+	var unresolved []string
+	seen := map[string]bool{}
+
+	for _, entry := range c.Entries {
+		var app models.Application
+		var ok bool
+		if entry.Guid != "" {
+			app, ok = byGuid[entry.Guid]
+		} else {
+			app, ok = byName[entry.SpaceGuid+"/"+entry.Name]
+		}
+
+		if !ok {
+			unresolved = append(unresolved, entry.Entry)
+			continue
+		}
+
+		if !seen[app.Guid] {
+			seen[app.Guid] = true
+			applications = append(applications, app)
+		}
+	}
+
+	if len(unresolved) > 0 {
+		return noApps, UnresolvedAppsErr{Entries: unresolved}
+	}
+
+	return c.Filter.Apply(applications, lookups)
+}
+
+func (c AppsListGetter) fetch(
+	appsParser ApplicationsParser,
+	paginatedRequester PaginatedRequester,
+	filter api.Filter,
+) (models.Applications, error) {
+	var noApps models.Applications
+
+	params := map[string]interface{}{}
+
+	pages := paginatedRequester.Pages(filter, params)
+	defer pages.Close()
+
+	var applications models.Applications
+
+	for pages.Next() {
+		apps, err := appsParser.Parse(pages.Body())
+		if err != nil {
+			return noApps, err
+		}
+
+		applications = append(applications, apps...)
+	}
+
+	if err := pages.Err(); err != nil {
+		return noApps, ListErr{Resource: "apps", Err: err}
+	}
+
+	return applications, nil
+}
+
+func batchSize(remaining int) int {
+	if remaining > appsListBatchSize {
+		return appsListBatchSize
+	}
+
+	return remaining
+}
+
+func uniqueValues(entries []appsListEntry, value func(appsListEntry) string) []interface{} {
+	var values []interface{}
+	seen := map[string]bool{}
+
+	for _, entry := range entries {
+		v := value(entry)
+		if !seen[v] {
+			seen[v] = true
+			values = append(values, v)
+		}
+	}
+
+	return values
+}
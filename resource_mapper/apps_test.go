@@ -0,0 +1,131 @@
+package resource_mapper_test
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry-incubator/app-restarter/api"
+	"github.com/cloudfoundry-incubator/app-restarter/api/apifakes"
+	"github.com/cloudfoundry-incubator/app-restarter/models"
+	. "github.com/cloudfoundry-incubator/app-restarter/resource_mapper"
+	"github.com/cloudfoundry/cli/plugin/models"
+)
+
+// pagesStub hands out a single page, which names the filter it was
+// requested with.
+type pagesStub struct {
+	body []byte
+	done bool
+}
+
+func (p *pagesStub) Next() bool {
+	if p.done {
+		return false
+	}
+	p.done = true
+	return true
+}
+
+func (p *pagesStub) Body() []byte { return p.body }
+func (p *pagesStub) Err() error   { return nil }
+func (p *pagesStub) Close()       {}
+
+type requesterStub struct {
+	filters []api.Filter
+}
+
+func (r *requesterStub) Pages(filter api.Filter, params map[string]interface{}) api.PageIterator {
+	r.filters = append(r.filters, filter)
+	return &pagesStub{body: []byte(filter.ToFilterQueryParam())}
+}
+
+// parserStub turns every page into an app named by the page.
+type parserStub struct{}
+
+func (p parserStub) Parse(body []byte) (models.Applications, error) {
+	return models.Applications{app(string(body), "")}, nil
+}
+
+var _ = Describe("AppsGetter", func() {
+	var (
+		cliConnection *apifakes.FakeConnection
+		requester     *requesterStub
+
+		orgNames   []string
+		spaceNames []string
+	)
+
+	orgs := map[string]plugin_models.GetOrg_Model{}
+	for _, name := range []string{"org-a", "org-b"} {
+		org := plugin_models.GetOrg_Model{Guid: name + "-guid", Name: name}
+		for i := 0; i < 60; i++ {
+			org.Spaces = append(org.Spaces, plugin_models.GetOrg_Space{
+				Guid: fmt.Sprintf("%s-space-%d-guid", name, i),
+				Name: fmt.Sprintf("space-%d", i),
+			})
+		}
+		orgs[name] = org
+	}
+
+	inclusionFilter := func(name string, first int, last int) api.Filter {
+		var values []interface{}
+		for i := first; i <= last; i++ {
+			values = append(values, fmt.Sprintf("org-a-space-%d-guid", i))
+		}
+		return api.InclusionFilter{Name: name, Values: values}
+	}
+
+	BeforeEach(func() {
+		cliConnection = new(apifakes.FakeConnection)
+		cliConnection.GetOrgStub = func(name string) (plugin_models.GetOrg_Model, error) {
+			return orgs[name], nil
+		}
+		cliConnection.GetSpaceReturns(plugin_models.GetSpace_Model{
+			GetSpaces_Model: plugin_models.GetSpaces_Model{Guid: "other-space-guid", Name: "other-space"},
+		}, nil)
+
+		requester = &requesterStub{}
+
+		orgNames = nil
+		spaceNames = nil
+	})
+
+	apps := func() models.Applications {
+		getter, err := NewAppsGetterFunc(cliConnection, orgNames, spaceNames, AppsFilter{})
+		Expect(err).NotTo(HaveOccurred())
+
+		apps, err := getter(parserStub{}, requester, Lookups{})
+		Expect(err).NotTo(HaveOccurred())
+		return apps
+	}
+
+	It("fetches all apps without orgs or spaces", func() {
+		Expect(apps()).To(HaveLen(1))
+		Expect(requester.filters).To(Equal([]api.Filter{api.Filters{}}))
+	})
+
+	It("filters by the orgs when only orgs are given", func() {
+		orgNames = []string{"org-a", "org-b"}
+
+		Expect(apps()).To(HaveLen(1))
+		Expect(requester.filters).To(Equal([]api.Filter{
+			api.InclusionFilter{Name: "organization_guid", Values: []interface{}{"org-a-guid", "org-b-guid"}},
+		}))
+	})
+
+	It("filters by the spaces of the orgs in batches when spaces are given, too", func() {
+		orgNames = []string{"org-a"}
+		spaceNames = []string{"other-space"}
+
+		Expect(apps()).To(HaveLen(2))
+
+		lastBatch := inclusionFilter("space_guid", 50, 59).(api.InclusionFilter)
+		lastBatch.Values = append(lastBatch.Values, "other-space-guid")
+		Expect(requester.filters).To(Equal([]api.Filter{
+			inclusionFilter("space_guid", 0, 49),
+			lastBatch,
+		}))
+	})
+})
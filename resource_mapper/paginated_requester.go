@@ -1,8 +1,23 @@
 package resource_mapper
 
-import "github.com/cloudfoundry-incubator/app-restarter/api"
+import (
+	"fmt"
+
+	"github.com/cloudfoundry-incubator/app-restarter/api"
+)
 
 //go:generate counterfeiter . PaginatedRequester
 type PaginatedRequester interface {
-	Do(filter api.Filter, params map[string]interface{}) ([][]byte, error)
+	Pages(filter api.Filter, params map[string]interface{}) api.PageIterator
+}
+
+// ListErr names the resources that could not be listed. Err is the
+// api.CloudControllerError when the Cloud Controller rejected the request.
+type ListErr struct {
+	Resource string
+	Err      error
+}
+
+func (e ListErr) Error() string {
+	return fmt.Sprintf("Failed to list %s: %s", e.Resource, e.Err.Error())
 }
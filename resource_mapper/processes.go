@@ -0,0 +1,54 @@
+package resource_mapper
+
+import (
+	"github.com/cloudfoundry-incubator/app-restarter/api"
+	"github.com/cloudfoundry-incubator/app-restarter/models"
+)
+
+type ProcessesParser interface {
+	Parse([]byte) (models.Processes, error)
+}
+
+// WebProcessMemory returns the memory of the web process of each app by app
+// guid. v3 only reports the memory on the processes of an app.
+func WebProcessMemory(
+	processesParser ProcessesParser,
+	paginatedRequester PaginatedRequester,
+	apps models.Applications,
+) (map[string]int, error) {
+	var guids []interface{}
+	for _, app := range apps {
+		guids = append(guids, app.Guid)
+	}
+
+	memory := map[string]int{}
+
+	for len(guids) > 0 {
+		batch := guids[:batchSize(len(guids))]
+		guids = guids[len(batch):]
+
+		pages := paginatedRequester.Pages(api.InclusionFilter{Name: "app_guid", Values: batch}, map[string]interface{}{})
+
+		for pages.Next() {
+			processes, err := processesParser.Parse(pages.Body())
+			if err != nil {
+				pages.Close()
+				return nil, err
+			}
+
+			for _, process := range processes {
+				if process.Type == "web" {
+					memory[process.AppGuid] = process.Memory
+				}
+			}
+		}
+
+		err := pages.Err()
+		pages.Close()
+		if err != nil {
+			return nil, ListErr{Resource: "processes", Err: err}
+		}
+	}
+
+	return memory, nil
+}
@@ -0,0 +1,13 @@
+package resource_mapper_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestResourceMapper(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "ResourceMapper Suite")
+}
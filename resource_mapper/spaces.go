@@ -15,19 +15,15 @@ func Spaces(spacesParser SpacesParser, paginatedRequester PaginatedRequester) (m
 
 	filter := api.Filters{}
 
-	params := map[string]interface{}{
-		"inline-relations-depth": 1,
-	}
+	params := map[string]interface{}{}
 
-	responseBodies, err := paginatedRequester.Do(filter, params)
-	if err != nil {
-		return noSpaces, err
-	}
+	pages := paginatedRequester.Pages(filter, params)
+	defer pages.Close()
 
 	var spaces models.Spaces
 
-	for _, nextBody := range responseBodies {
-		apps, err := spacesParser.Parse(nextBody)
+	for pages.Next() {
+		apps, err := spacesParser.Parse(pages.Body())
 		if err != nil {
 			return noSpaces, err
 		}
@@ -35,5 +31,9 @@ func Spaces(spacesParser SpacesParser, paginatedRequester PaginatedRequester) (m
 		spaces = append(spaces, apps...)
 	}
 
+	if err := pages.Err(); err != nil {
+		return noSpaces, ListErr{Resource: "spaces", Err: err}
+	}
+
 	return spaces, nil
 }
@@ -1,13 +1,17 @@
 package ui
 
-import "github.com/fatih/color"
+import (
+	"io"
+
+	"github.com/fatih/color"
+)
 
 func SayOK() {
 	c := color.New(color.FgGreen).Add(color.Bold)
 	c.Println("OK\n")
 }
 
-func SayFailed() {
+func SayFailed(out io.Writer) {
 	c := color.New(color.FgRed).Add(color.Bold)
-	c.Println("FAILED")
+	c.Fprintln(out, "FAILED")
 }
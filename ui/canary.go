@@ -0,0 +1,42 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry/cli/cf/terminal"
+)
+
+func (c *RestartApps) BeforeCanaries(canaries int) {
+	fmt.Fprintf(c.Out, "Restarting %d canary apps first...\n", canaries)
+}
+
+func (c *RestartApps) WaitAfterCanaries(wait time.Duration, remaining int) {
+	fmt.Fprintln(c.Out)
+	fmt.Fprintf(
+		c.Out,
+		"Canary apps restarted successfully, waiting %s before restarting the remaining %d apps...\n",
+		terminal.EntityNameColor(wait.String()),
+		remaining,
+	)
+}
+
+// ConfirmAfterCanaries asks whether to go on with the remaining apps unless
+// Force is set. Any answer but yes, including no answer at all, stops the
+// run, and so does an interrupt while waiting for the answer.
+func (c *RestartApps) ConfirmAfterCanaries(ctx context.Context, remaining int) bool {
+	fmt.Fprintln(c.Out)
+
+	if c.Force {
+		fmt.Fprintf(c.Out, "Canary apps restarted successfully, restarting the remaining %d apps...\n", remaining)
+		return true
+	}
+
+	fmt.Fprintf(c.Out, "Canary apps restarted successfully. Restart the remaining %d apps? [y/N] ", remaining)
+
+	answer := strings.ToLower(c.readAnswer(ctx))
+
+	return answer == "y" || answer == "yes"
+}
@@ -0,0 +1,111 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/cloudfoundry/cli/cf/terminal"
+)
+
+// OrgScope counts the apps of an org that are about to be restarted and the
+// spaces they are in.
+type OrgScope struct {
+	Name   string
+	Spaces int
+	Apps   int
+}
+
+type RestartScope []OrgScope
+
+func (s RestartScope) Apps() int {
+	apps := 0
+	for _, org := range s {
+		apps += org.Apps
+	}
+
+	return apps
+}
+
+func (s RestartScope) Spaces() int {
+	spaces := 0
+	for _, org := range s {
+		spaces += org.Spaces
+	}
+
+	return spaces
+}
+
+// confirmScope shows how many apps are about to be restarted and asks for
+// confirmation unless Force is set. The answer must be the org name when a
+// single org is in scope and yes otherwise.
+func (c *RestartApps) confirmScope(ctx context.Context, scope RestartScope) bool {
+	fmt.Fprintf(
+		c.Out,
+		"%d apps in %d spaces of %d orgs will be restarted:\n",
+		scope.Apps(),
+		scope.Spaces(),
+		len(scope),
+	)
+	for _, org := range scope {
+		fmt.Fprintf(
+			c.Out,
+			"   %s: %d apps in %d spaces\n",
+			terminal.EntityNameColor(org.Name),
+			org.Apps,
+			org.Spaces,
+		)
+	}
+	fmt.Fprintln(c.Out)
+
+	if c.Force || scope.Apps() == 0 {
+		return true
+	}
+
+	expected := "yes"
+	if len(c.Organizations) == 1 && len(c.Spaces) == 0 {
+		expected = c.Organizations[0]
+	}
+
+	fmt.Fprintf(c.Out, "Type %s to restart them: ", terminal.EntityNameColor(expected))
+	answer := c.readAnswer(ctx)
+	fmt.Fprintln(c.Out)
+
+	return answer == expected
+}
+
+// readAnswer reads a line from In. It reads a byte at a time, so that no
+// answer to a later prompt is lost to buffering. It gives up with no answer
+// once the context is cancelled, leaving the read to finish on its own, as
+// there are no further prompts after an interrupt.
+func (c *RestartApps) readAnswer(ctx context.Context) string {
+	answers := make(chan string, 1)
+
+	go func() {
+		var answer []byte
+		b := make([]byte, 1)
+
+		for {
+			n, err := c.In.Read(b)
+			if n == 1 {
+				if b[0] == '\n' {
+					break
+				}
+				answer = append(answer, b[0])
+			}
+			if err == io.EOF || (err != nil && n == 0) {
+				break
+			}
+		}
+
+		answers <- strings.TrimSpace(string(answer))
+	}()
+
+	select {
+	case answer := <-answers:
+		return answer
+	case <-ctx.Done():
+		return ""
+	}
+}
@@ -0,0 +1,60 @@
+package ui
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+type AppRecord struct {
+	Guid          string    `json:"guid"`
+	Name          string    `json:"name"`
+	Organization  string    `json:"org"`
+	Space         string    `json:"space"`
+	PreviousState string    `json:"previous_state"`
+	Outcome       string    `json:"outcome"`
+	Error         string    `json:"error,omitempty"`
+	Retries       int       `json:"retries"`
+	StartedAt     time.Time `json:"started_at"`
+	FinishedAt    time.Time `json:"finished_at"`
+}
+
+type ReportSummary struct {
+	RestartSummary
+	Restarted int `json:"restarted"`
+}
+
+type Report struct {
+	Apps    []AppRecord   `json:"apps"`
+	Summary ReportSummary `json:"summary"`
+}
+
+// RestartReport writes a machine-readable report of the run to stdout, to a
+// file, or both.
+type RestartReport struct {
+	JSON bool
+	File string
+}
+
+func (r *RestartReport) Write(report Report) error {
+	report.Summary.Restarted = report.Summary.Successes()
+
+	body, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if r.JSON {
+		_, err = os.Stdout.Write(append(body, '\n'))
+		if err != nil {
+			return err
+		}
+	}
+
+	if r.File != "" {
+		return ioutil.WriteFile(r.File, append(body, '\n'), 0644)
+	}
+
+	return nil
+}
@@ -1,111 +1,302 @@
 package ui
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/cloudfoundry-incubator/app-restarter/api"
 	"github.com/cloudfoundry/cli/cf/terminal"
 )
 
+type RestartSummary struct {
+	Attempts int `json:"attempts"`
+	Stopped  int `json:"stopped"`
+	Warnings int `json:"warnings"`
+	Errors   int `json:"errors"`
+	Crashed  int `json:"crashed"`
+	TimedOut int `json:"timed_out"`
+	Skipped  int `json:"skipped"`
+
+	StagingFailed int `json:"staging_failed"`
+	NotAttempted  int `json:"not_attempted"`
+}
+
+func (s RestartSummary) Add(other RestartSummary) RestartSummary {
+	return RestartSummary{
+		Attempts: s.Attempts + other.Attempts,
+		Stopped:  s.Stopped + other.Stopped,
+		Warnings: s.Warnings + other.Warnings,
+		Errors:   s.Errors + other.Errors,
+		Crashed:  s.Crashed + other.Crashed,
+		TimedOut: s.TimedOut + other.TimedOut,
+		Skipped:  s.Skipped + other.Skipped,
+
+		StagingFailed: s.StagingFailed + other.StagingFailed,
+		NotAttempted:  s.NotAttempted + other.NotAttempted,
+	}
+}
+
+// Failures counts the apps that did not come back after restarting.
+func (s RestartSummary) Failures() int {
+	return s.Errors + s.Crashed + s.TimedOut + s.StagingFailed
+}
+
+func (s RestartSummary) Successes() int {
+	return s.Attempts - s.Stopped - s.Warnings - s.Errors - s.Crashed - s.TimedOut - s.Skipped - s.StagingFailed - s.NotAttempted
+}
+
+// FailedApp is an app that failed to restart, listed once all apps are done.
+type FailedApp struct {
+	App    ApplicationPrinter
+	Reason string
+}
+
 type RestartApps struct {
-	Username     string
-	Organization string
-	Space        string
+	Username      string
+	Organizations []string
+	Spaces        []string
+	Out           io.Writer
+	In            io.Reader
+	Force         bool
+
+	lock sync.Mutex
 }
 
-func NewRestartApps(cliConnection api.Connection, organizationName string, spaceName string) (RestartApps, error) {
+func NewRestartApps(cliConnection api.Connection, organizationNames []string, spaceNames []string) (RestartApps, error) {
 	username, err := cliConnection.Username()
 	if err != nil {
 		return RestartApps{}, err
 	}
 
-	if spaceName != "" {
-		space, err := cliConnection.GetSpace(spaceName)
-		if err != nil || space.Guid == "" {
-			return RestartApps{}, err
+	var spaces []string
+	for _, spaceName := range spaceNames {
+		if !strings.Contains(spaceName, "/") {
+			space, err := cliConnection.GetSpace(spaceName)
+			if err != nil || space.Guid == "" {
+				return RestartApps{}, err
+			}
+			spaceName = space.Organization.Name + "/" + spaceName
 		}
-		organizationName = space.Organization.Name
+		spaces = append(spaces, spaceName)
 	}
 
 	return RestartApps{
-		Username:     username,
-		Organization: organizationName,
-		Space:        spaceName,
+		Username:      username,
+		Organizations: organizationNames,
+		Spaces:        spaces,
+		Out:           os.Stdout,
+		In:            os.Stdin,
 	}, nil
 }
 
-func (c *RestartApps) BeforeAll() {
-	switch {
-	case c.Organization != "" && c.Space != "":
-		fmt.Printf(
-			"Restarting apps in org %s / %s as %s...\n",
-			terminal.EntityNameColor(c.Organization),
-			terminal.EntityNameColor(c.Space),
-			terminal.EntityNameColor(c.Username),
-		)
-	case c.Organization != "":
-		fmt.Printf(
-			"Restarting apps in org %s as %s...\n",
-			terminal.EntityNameColor(c.Organization),
-			terminal.EntityNameColor(c.Username),
-		)
-	default:
-		fmt.Printf(
+// BeforeAll shows the apps in scope and reports whether the user confirmed
+// restarting them.
+func (c *RestartApps) BeforeAll(ctx context.Context, scope RestartScope) bool {
+	if !c.confirmScope(ctx, scope) {
+		return false
+	}
+
+	var scopes []string
+	for _, org := range c.Organizations {
+		scopes = append(scopes, "org "+terminal.EntityNameColor(org))
+	}
+	for _, space := range c.Spaces {
+		parts := strings.SplitN(space, "/", 2)
+		scopes = append(scopes, fmt.Sprintf(
+			"org %s / %s",
+			terminal.EntityNameColor(parts[0]),
+			terminal.EntityNameColor(parts[1]),
+		))
+	}
+
+	if len(scopes) == 0 {
+		fmt.Fprintf(
+			c.Out,
 			"Restarting apps as %s...\n",
 			terminal.EntityNameColor(c.Username),
 		)
+		return true
 	}
-}
 
-func (c *RestartApps) BeforeEach(app ApplicationPrinter) {
-	fmt.Println()
-	fmt.Printf(
-		"Restarting app %s in org %s / space %s as %s...\n",
-		terminal.EntityNameColor(app.Name()),
-		terminal.EntityNameColor(app.Organization()),
-		terminal.EntityNameColor(app.Space()),
+	fmt.Fprintf(
+		c.Out,
+		"Restarting apps in %s as %s...\n",
+		strings.Join(scopes, ", "),
 		terminal.EntityNameColor(c.Username),
 	)
+	return true
+}
+
+func (c *RestartApps) BeforeEach(app ApplicationPrinter) {
+	c.sayForApp(app, "Restarting app as %s...", terminal.EntityNameColor(c.Username))
+}
+
+func (c *RestartApps) StartingAgain(app ApplicationPrinter) {
+	c.sayForApp(app, "WARNING: App was stopped but not started again, starting it...")
+}
+
+func (c *RestartApps) StagingEach(app ApplicationPrinter) {
+	c.sayForApp(app, "Waiting for app to stage...")
+}
+
+func (c *RestartApps) StagingFailedEach(app ApplicationPrinter, err error) {
+	c.sayForApp(app, "Error: %s", terminal.EntityNameColor(err.Error()))
+}
+
+func (c *RestartApps) RestartingInstance(app ApplicationPrinter, index string) {
+	c.sayForApp(app, "Restarting instance %s...", terminal.EntityNameColor(index))
 }
 
 func (c *RestartApps) CompletedEach(app ApplicationPrinter) {
-	fmt.Println()
-	fmt.Printf(
-		"Completed restarting app %s in org %s / space %s as %s\n",
-		terminal.EntityNameColor(app.Name()),
-		terminal.EntityNameColor(app.Organization()),
-		terminal.EntityNameColor(app.Space()),
-		terminal.EntityNameColor(c.Username),
-	)
+	c.sayForApp(app, "Completed restarting app as %s", terminal.EntityNameColor(c.Username))
+}
+
+func (c *RestartApps) CrashedEach(app ApplicationPrinter) {
+	c.sayForApp(app, "Error: App crashed after restarting")
+}
+
+func (c *RestartApps) TimedOutEach(app ApplicationPrinter, timeout time.Duration) {
+	c.sayForApp(app, "WARNING: App did not start within %s", terminal.EntityNameColor(timeout.String()))
+}
+
+func (c *RestartApps) SkippedEach(app ApplicationPrinter) {
+	c.sayForApp(app, "Skipping app already restarted by a previous run")
+}
+
+func (c *RestartApps) FailCheckpoint(app ApplicationPrinter, err error) {
+	c.sayForApp(app, "WARNING: Failed to record outcome in state file: %s", terminal.EntityNameColor(err.Error()))
 }
 
 func (c *RestartApps) DuringEach(app ApplicationPrinter) {
-	fmt.Print(".")
+	c.sayForApp(app, "Waiting for app to start...")
 }
 
-func (c *RestartApps) AfterAll(attempts, stopped, warnings int, errors int) {
-	successes := attempts - stopped - warnings - errors
-	fmt.Println()
-	fmt.Printf("Restarting completed: %d apps restarted, %d apps already stopped, %d errors, %d warnings\n", successes, stopped, errors, warnings)
+func (c *RestartApps) RetryingEach(app ApplicationPrinter, attempt int, maxAttempts int, err error) {
+	c.sayForApp(
+		app,
+		"Retrying after transient error (attempt %d of %d): %s",
+		attempt,
+		maxAttempts,
+		terminal.EntityNameColor(err.Error()),
+	)
 }
 
-func (c *RestartApps) UserWarning(app ApplicationPrinter) {
-	fmt.Printf(
-		"WARNING: No authorization to restart app %s in space %s / org %s as %s\n",
-		terminal.EntityNameColor(app.Name()),
-		terminal.EntityNameColor(app.Space()),
-		terminal.EntityNameColor(app.Organization()),
-		terminal.EntityNameColor(c.Username),
+func (c *RestartApps) AfterAll(summary RestartSummary, failed []FailedApp) {
+	fmt.Fprintln(c.Out)
+	fmt.Fprintf(
+		c.Out,
+		"Restarting completed: %d apps restarted, %d apps already stopped, %d crashed, %d timed out, %d errors, %d warnings\n",
+		summary.Successes(),
+		summary.Stopped,
+		summary.Crashed,
+		summary.TimedOut,
+		summary.Errors,
+		summary.Warnings,
 	)
+
+	if summary.StagingFailed > 0 {
+		fmt.Fprintf(c.Out, "%d apps failed to stage\n", summary.StagingFailed)
+	}
+
+	if summary.Skipped > 0 {
+		fmt.Fprintf(c.Out, "%d apps skipped as already restarted by a previous run\n", summary.Skipped)
+	}
+
+	if len(failed) == 0 {
+		return
+	}
+
+	fmt.Fprintf(c.Out, "%d apps failed to restart:\n", len(failed))
+	for _, app := range failed {
+		fmt.Fprintf(
+			c.Out,
+			"   %s / %s / %s: %s\n",
+			terminal.EntityNameColor(app.App.Organization()),
+			terminal.EntityNameColor(app.App.Space()),
+			terminal.EntityNameColor(app.App.Name()),
+			app.Reason,
+		)
+	}
+}
+
+func (c *RestartApps) NotAttempted(apps []ApplicationPrinter) {
+	if len(apps) == 0 {
+		return
+	}
+
+	fmt.Fprintf(c.Out, "%d apps were not attempted:\n", len(apps))
+	for _, app := range apps {
+		fmt.Fprintf(
+			c.Out,
+			"   %s / %s / %s\n",
+			terminal.EntityNameColor(app.Organization()),
+			terminal.EntityNameColor(app.Space()),
+			terminal.EntityNameColor(app.Name()),
+		)
+	}
+}
+
+func (c *RestartApps) WaitingForWindow(opens time.Time, remaining int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	fmt.Fprintf(
+		c.Out,
+		"Outside the maintenance window, waiting until %s to restart the remaining %d apps...\n",
+		terminal.EntityNameColor(opens.Format("2006-01-02 15:04 MST")),
+		remaining,
+	)
+}
+
+func (c *RestartApps) WindowClosed(remaining int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	fmt.Fprintf(
+		c.Out,
+		"Outside the maintenance window, %d apps remain to be restarted. Run again with --resume to restart them.\n",
+		remaining,
+	)
+}
+
+func (c *RestartApps) Interrupted() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	fmt.Fprintln(c.Out)
+	fmt.Fprintln(c.Out, "Interrupted, finishing the apps being restarted. Interrupt again to exit right away.")
+}
+
+func (c *RestartApps) UserWarning(app ApplicationPrinter) {
+	c.sayForApp(app, "WARNING: No authorization to restart app as %s", terminal.EntityNameColor(c.Username))
 }
 
 func (c *RestartApps) FailRestart(app ApplicationPrinter, err error) {
-	fmt.Printf(
-		"Error: Failed to restart app %s in space %s / org %s as %s: %s",
-		terminal.EntityNameColor(app.Name()),
-		terminal.EntityNameColor(app.Space()),
-		terminal.EntityNameColor(app.Organization()),
+	c.sayForApp(
+		app,
+		"Error: Failed to restart app as %s: %s",
 		terminal.EntityNameColor(c.Username),
 		terminal.EntityNameColor(err.Error()),
 	)
 }
+
+// sayForApp prints a single line prefixed with the app it is about, so that
+// output of apps restarted in parallel can be told apart.
+func (c *RestartApps) sayForApp(app ApplicationPrinter, format string, args ...interface{}) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	fmt.Fprintf(
+		c.Out,
+		"[%s / %s / %s] %s\n",
+		terminal.EntityNameColor(app.Organization()),
+		terminal.EntityNameColor(app.Space()),
+		terminal.EntityNameColor(app.Name()),
+		fmt.Sprintf(format, args...),
+	)
+}
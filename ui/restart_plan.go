@@ -0,0 +1,71 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/cloudfoundry/cli/cf/terminal"
+)
+
+type PlanEntry struct {
+	App       ApplicationPrinter
+	State     string
+	Skipped   bool
+	Restarted bool
+	Canary    bool
+}
+
+// RestartPlan shows the apps a run would restart. Out is stderr when the
+// report goes to stdout.
+type RestartPlan struct {
+	Username string
+	Restage  bool
+	Out      io.Writer
+}
+
+func (p *RestartPlan) Show(entries []PlanEntry) {
+	fmt.Fprintf(
+		p.Out,
+		"Restart plan for %d apps as %s (dry run, no apps will be restarted):\n\n",
+		len(entries),
+		terminal.EntityNameColor(p.Username),
+	)
+
+	skipped := 0
+
+	table := tabwriter.NewWriter(p.Out, 0, 4, 3, ' ', 0)
+	fmt.Fprintln(table, "#\torg\tspace\tapp\tstate\taction")
+	for i, entry := range entries {
+		action := "restart"
+		if p.Restage {
+			action = "restage"
+		}
+
+		switch {
+		case entry.Restarted:
+			action = "skip (already restarted)"
+			skipped++
+		case entry.Skipped:
+			action = "skip (stopped)"
+			skipped++
+		case entry.Canary:
+			action += " (canary)"
+		}
+
+		fmt.Fprintf(
+			table,
+			"%d\t%s\t%s\t%s\t%s\t%s\n",
+			i+1,
+			entry.App.Organization(),
+			entry.App.Space(),
+			entry.App.Name(),
+			entry.State,
+			action,
+		)
+	}
+	table.Flush()
+
+	fmt.Fprintln(p.Out)
+	fmt.Fprintf(p.Out, "%d apps would be restarted, %d apps would be skipped\n", len(entries)-skipped, skipped)
+}